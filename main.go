@@ -4,88 +4,36 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	//"os"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/layneYoo/mCtl/check"
 	mctl "github.com/layneYoo/mCtl/marathon"
 )
 
-/*
-const Help = `marathonctl <flags...> [mctl.Action] <args...>
- mctl.Actions
-    app
-       list                      - list all apps
-       versions [id]             - list all versions of apps of id
-       show [id]                 - show config and status of app of id (latest version)
-       show [id] [version]       - show config and status of app of id and version
-       create [jsonfile]         - deploy application defined in jsonfile
-       update [jsonfile]         - update application as defined in jsonfile
-       update [id] [jsonfile]    - update application id as defined in jsonfile
-       update cpu [id] [cpu%]    - update application id to have cpu% of cpu share
-       update memory [id] [MB]   - update application id to have MB of memory
-       update instances [id] [N] - update application id to have N instances
-       restart [id]              - restart app of id
-       destroy [id]              - destroy and remove all instances of id
-
-    task
-       list               - list all tasks
-       list [id]          - list tasks of app of id
-       kill [id]          - kill all tasks of app id
-       kill [id] [taskid] - kill task taskid of app id
-       queue              - list all queued tasks
-
-    group
-       list                        - list all groups
-       list [groupid]              - list groups in groupid
-       create [jsonfile]           - create a group defined in jsonfile
-       update [groupid] [jsonfile] - update group groupid as defined in jsonfile
-       destroy [groupid]           - destroy group of groupid
-
-    deploy
-       list               - list all active deploys
-       destroy [deployid] - cancel deployment of [deployid]
-
-    marathon
-       leader   - get the current Marathon leader
-       abdicate - force the current leader to relinquish control
-       ping     - ping Marathon master host[s]
-
-    artifact
-       upload [path] [file]   - upload artifact to artifacts store
-       get [path]             - get artifact from store
-       delete [path]          - delete artifact from store
-
- Flags
-  -c [config file]
-  -h [host]
-  -u [user:password] (separated by colon)
-  -f [format]
-       human  (simplified columns, default)
-       json   (json on one line)
-       jsonpp (json pretty printed)
-       raw    (the exact response from Marathon)
-`
-
-func Usage() {
-	fmt.Fprintln(os.Stderr, Help)
-	os.Exit(1)
-}
-*/
-
 func main() {
-	host, login, format, e := mctl.Config()
-
-	if e != nil {
-		fmt.Printf("config error: %s\n\n", e)
-		check.Usage()
+	s, e := mctl.Config()
+	completing := os.Getenv(mctl.CompleteEnv) != ""
+
+	if e != nil && e != mctl.ErrNoHost {
+		if completing {
+			return
+		}
+		fmt.Printf("config error: %s\n", e)
+		os.Exit(mctl.ExitError)
 	}
 
-	f := mctl.NewFormatter(format)
-	l := mctl.NewLogin(host, login)
-	c := mctl.NewClient(l)
+	f := mctl.NewFormatter(s.Format).WithTable(s.Table)
+	l := mctl.NewLogin(s.Host, s.Login, s.Secrets...)
+	l.RoundRobin = s.RoundRobin
+	c, ce := mctl.NewClient(l, s.Options)
 	app := &mctl.Category{
+		Summary: "Marathon applications",
 		Actions: map[string]mctl.Action{
 			"list":     mctl.AppList{c, f},
 			"versions": mctl.AppVersions{c, f},
@@ -97,6 +45,7 @@ func main() {
 		},
 	}
 	task := &mctl.Category{
+		Summary: "tasks of applications",
 		Actions: map[string]mctl.Action{
 			"list":  mctl.TaskList{c, f},
 			"kill":  mctl.TaskKill{c, f},
@@ -104,6 +53,7 @@ func main() {
 		},
 	}
 	group := &mctl.Category{
+		Summary: "application groups",
 		Actions: map[string]mctl.Action{
 			"list":    mctl.GroupList{c, f},
 			"create":  mctl.GroupCreate{c, f},
@@ -112,12 +62,14 @@ func main() {
 		},
 	}
 	deploy := &mctl.Category{
+		Summary: "deployments in progress",
 		Actions: map[string]mctl.Action{
 			"list":   mctl.DeployList{c, f},
 			"cancel": mctl.DeployCancel{c, f},
 		},
 	}
 	marathon := &mctl.Category{
+		Summary: "the Marathon cluster",
 		Actions: map[string]mctl.Action{
 			"leader":   mctl.MarathonLeader{c, f},
 			"abdicate": mctl.MarathonAbdicate{c, f},
@@ -125,12 +77,56 @@ func main() {
 		},
 	}
 	artifact := &mctl.Category{
+		Summary: "the artifact store",
 		Actions: map[string]mctl.Action{
 			"upload": mctl.ArtifactUpload{c, f},
 			"get":    mctl.ArtifactGet{c, f},
 			"delete": mctl.ArtifactDelete{c, f},
 		},
 	}
+	auth := &mctl.Category{
+		Summary: "credentials",
+		Actions: map[string]mctl.Action{
+			"login":  mctl.AuthLogin{c, f},
+			"set":    mctl.AuthSet{c, f},
+			"remove": mctl.AuthRemove{c, f},
+		},
+	}
+	contexts := &mctl.Category{
+		Summary: "contexts of the config file",
+		Actions: map[string]mctl.Action{
+			"list":    mctl.ContextList{s, f},
+			"use":     mctl.ContextUse{s, f},
+			"current": mctl.ContextCurrent{s, f},
+			"show":    mctl.ContextShow{s, f},
+		},
+		Local: true,
+	}
+	config := &mctl.Category{
+		Summary: "the config file",
+		Actions: map[string]mctl.Action{
+			"init":     mctl.ConfigInit{s, f},
+			"view":     mctl.ConfigView{s, f},
+			"set":      mctl.ConfigSet{s, f},
+			"validate": mctl.ConfigValidate{s, f},
+			"convert":  mctl.ConfigConvert{s, f},
+		},
+		Local: true,
+	}
+	plugin := &mctl.Category{
+		Summary: "marathonctl-<name> commands on PATH, run as marathonctl <name>",
+		Actions: map[string]mctl.Action{
+			"list": mctl.PluginList{f},
+		},
+		Local: true,
+	}
+	alias := &mctl.Category{
+		Summary: "aliases of the config file",
+		Actions: map[string]mctl.Action{
+			"list": mctl.AliasList{s, f},
+		},
+		Local: true,
+	}
 	t := &mctl.Tool{
 		Selections: map[string]mctl.Selector{
 			"app":      app,
@@ -139,10 +135,74 @@ func main() {
 			"deploy":   deploy,
 			"marathon": marathon,
 			"artifact": artifact,
+			"auth":     auth,
+			"context":  contexts,
+			"config":   config,
+			"plugin":   plugin,
+			"alias":    alias,
+		},
+		Aliases: s.Aliases,
+		Plugin: func(path string) mctl.Selector {
+			return mctl.Plugin{path, s, l}
+		},
+	}
+
+	t.Selections["completion"] = &mctl.Category{
+		Summary: "shell completion scripts",
+		Actions: map[string]mctl.Action{
+			"bash": mctl.Completion{t, "bash"},
+			"zsh":  mctl.Completion{t, "zsh"},
+			"fish": mctl.Completion{t, "fish"},
 		},
+		Local: true,
+	}
+
+	if s.Help {
+		fmt.Print(t.Help())
+		return
+	}
+
+	if completing {
+		for _, candidate := range t.Complete(context.Background(), flag.Args()) {
+			fmt.Println(candidate)
+		}
+		return
 	}
 
-	t.Start(flag.Args())
+	if !t.Local(flag.Args()) {
+		if e == mctl.ErrNoHost {
+			fmt.Printf("config error: %s\n\n", e)
+			t.Usage()
+		}
+		check.Check(ce == nil, ce)
+	}
+
+	ctx, cancel := interruptible(s.Timeout)
+	defer cancel()
+	t.Start(ctx, flag.Args())
+}
+
+// interruptible returns a context which is cancelled on SIGINT or
+// SIGTERM, or once timeout has passed if it is not zero. A second
+// signal exits immediately.
+func interruptible(timeout time.Duration) (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		fmt.Fprintf(os.Stderr, "received %s, cancelling\n", sig)
+		cancel()
+		<-signals
+		os.Exit(mctl.ExitInterrupted)
+	}()
+	return ctx, cancel
 }
 
 /*
@@ -0,0 +1,160 @@
+package marathon
+
+// Aliases of the config file, short names for longer commands:
+//
+//	aliases.web-tasks: task list /team/api/web
+//	aliases.scale: app update instances /team/api/$1 $2
+//
+// and alias list to show them
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// aliasPrefix starts the keys of aliases in config files.
+const aliasPrefix = "aliases."
+
+// placeholder matches $1 to $9 and $@ in alias templates.
+var placeholder = regexp.MustCompile(`\$[1-9@]`)
+
+// readAliases returns the aliases defined by files, highest
+// precedence first, mapping names to templates.
+func readAliases(files []string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for i := len(files) - 1; i >= 0; i-- {
+		props, e := properties(files[i])
+		if e != nil {
+			return nil, e
+		}
+		for _, p := range props {
+			if strings.HasPrefix(p.Key, aliasPrefix) {
+				aliases[strings.TrimPrefix(p.Key, aliasPrefix)] = p.Value
+			}
+		}
+	}
+	return aliases, nil
+}
+
+// expand replaces an alias in args[0] by its template. Commands
+// take precedence over aliases of the same name.
+func (t *Tool) expand(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+	if _, ok := t.Selections[args[0]]; ok {
+		return args, nil
+	}
+	template, ok := t.Aliases[args[0]]
+	if !ok {
+		return args, nil
+	}
+	return expandAlias(args[0], template, args[1:])
+}
+
+// expandAlias splits template into words and fills in args, $1 to $9
+// being one argument and $@ all of them. Arguments not used by a
+// placeholder are added at the end.
+func expandAlias(name, template string, args []string) ([]string, error) {
+	var expanded []string
+	used := make([]bool, len(args))
+	var missing int
+	for _, word := range strings.Fields(template) {
+		if word == "$@" {
+			expanded = append(expanded, args...)
+			for i := range used {
+				used[i] = true
+			}
+			continue
+		}
+		word = placeholder.ReplaceAllStringFunc(word, func(p string) string {
+			if p == "$@" {
+				for i := range used {
+					used[i] = true
+				}
+				return strings.Join(args, " ")
+			}
+			n := int(p[1] - '1')
+			if n >= len(args) {
+				if n+1 > missing {
+					missing = n + 1
+				}
+				return p
+			}
+			used[n] = true
+			return args[n]
+		})
+		expanded = append(expanded, word)
+	}
+	if missing > 0 {
+		return nil, fmt.Errorf("alias %s takes %d arguments", name, missing)
+	}
+	for i, arg := range args {
+		if !used[i] {
+			expanded = append(expanded, arg)
+		}
+	}
+	return expanded, nil
+}
+
+// aliasNames returns the names of the aliases which are not hidden
+// by a command.
+func (t *Tool) aliasNames() []string {
+	var names []string
+	for name := range t.Aliases {
+		if _, ok := t.Selections[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+type alias struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+}
+
+// list
+type AliasList struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+func (a AliasList) Apply(ctx context.Context, args []string) {
+	check.Check(len(args) == 0, "no arguments")
+	list := []alias{}
+	for name, template := range a.Settings.Aliases {
+		list = append(list, alias{name, template})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	b, e := json.Marshal(list)
+	check.Check(e == nil, "failed to encode aliases", e)
+	fmt.Println(a.Formats.FormatTable(bytes.NewReader(b), a.Tabulate))
+}
+
+func (a AliasList) Usage() []Usage {
+	return []Usage{
+		{"", "list the aliases of the config files"},
+	}
+}
+
+func (a AliasList) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var list []alias
+	e := dec.Decode(&list)
+	check.Check(e == nil, "failed to decode aliases", e)
+	t := newTable("NAME", "TEMPLATE")
+	for _, alias := range list {
+		t.add(alias.Name, alias.Template)
+	}
+	return t
+}
@@ -0,0 +1,429 @@
+package marathon
+
+// Typed access to the Marathon REST API, for use from Go programs.
+// The actions of marathonctl are built on top of these methods.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type rawKey struct{}
+
+// withRaw returns a context which causes the response body of the
+// call made with it to be stored in raw, so that actions can print
+// exactly what Marathon said.
+func withRaw(ctx context.Context, raw *[]byte) context.Context {
+	return context.WithValue(ctx, rawKey{}, raw)
+}
+
+// call sends a request to path and decodes the JSON response into
+// out, if out is not nil.
+func (c *Client) call(ctx context.Context, method, path string, body io.Reader, out interface{}) error {
+	request, e := c.request(ctx, method, path, body)
+	if e != nil {
+		return e
+	}
+	response, e := c.Do(request)
+	if e != nil {
+		return e
+	}
+	defer response.Body.Close()
+	b, e := ioutil.ReadAll(response.Body)
+	if e != nil {
+		return fmt.Errorf("failed to read response: %v", e)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return newAPIError(method, path, response.StatusCode, b)
+	}
+	if raw, ok := ctx.Value(rawKey{}).(*[]byte); ok {
+		*raw = b
+	}
+	if out != nil && len(b) > 0 {
+		if e := json.Unmarshal(b, out); e != nil {
+			return fmt.Errorf("failed to decode response: %v", e)
+		}
+	}
+	return nil
+}
+
+// encode returns the JSON encoding of v as a request body.
+func encode(v interface{}) (io.Reader, error) {
+	b, e := json.Marshal(v)
+	if e != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", e)
+	}
+	return bytes.NewReader(b), nil
+}
+
+func escape(id string) string {
+	return url.QueryEscape(id)
+}
+
+// ListApps returns all applications.
+func (c *Client) ListApps(ctx context.Context) (*Applications, error) {
+	var apps Applications
+	if e := c.call(ctx, "GET", "/v2/apps", nil, &apps); e != nil {
+		return nil, e
+	}
+	return &apps, nil
+}
+
+// GetApp returns the current definition and status of application id.
+func (c *Client) GetApp(ctx context.Context, id string) (*Application, error) {
+	var app AppById
+	if e := c.call(ctx, "GET", "/v2/apps/"+escape(id), nil, &app); e != nil {
+		return nil, e
+	}
+	return &app.App, nil
+}
+
+// GetAppVersion returns application id as it was at version.
+func (c *Client) GetAppVersion(ctx context.Context, id, version string) (*Application, error) {
+	var app Application
+	path := "/v2/apps/" + escape(id) + "/versions/" + escape(version)
+	if e := c.call(ctx, "GET", path, nil, &app); e != nil {
+		return nil, e
+	}
+	return &app, nil
+}
+
+// ListAppVersions returns the versions of application id.
+func (c *Client) ListAppVersions(ctx context.Context, id string) (*Versions, error) {
+	var versions Versions
+	if e := c.call(ctx, "GET", "/v2/apps/"+escape(id)+"/versions", nil, &versions); e != nil {
+		return nil, e
+	}
+	return &versions, nil
+}
+
+// CreateApp deploys a new application.
+func (c *Client) CreateApp(ctx context.Context, app *Application) (*Application, error) {
+	body, e := encode(app)
+	if e != nil {
+		return nil, e
+	}
+	return c.CreateAppFrom(ctx, body)
+}
+
+// CreateAppFrom deploys a new application from its JSON definition.
+func (c *Client) CreateAppFrom(ctx context.Context, definition io.Reader) (*Application, error) {
+	var created Application
+	if e := c.call(ctx, "POST", "/v2/apps", definition, &created); e != nil {
+		return nil, e
+	}
+	return &created, nil
+}
+
+// UpdateApp changes application id to app, forcing the change even
+// if a deployment is in progress. Only the fields of app which are set
+// are changed, so it cannot scale to 0 instances, use UpdateAppFrom
+// for that. The fields Marathon reports but does not accept, like
+// Version and Tasks, are left out.
+func (c *Client) UpdateApp(ctx context.Context, id string, app *Application) (*Update, error) {
+	update := *app
+	update.Version = ""
+	update.Tasks = nil
+	update.TasksRunning = 0
+	update.TasksStaged = 0
+	update.DeploymentID = nil
+	body, e := encode(&update)
+	if e != nil {
+		return nil, e
+	}
+	return c.UpdateAppFrom(ctx, id, body)
+}
+
+// UpdateAppFrom changes application id as described by the JSON
+// definition, which may contain only the fields to change.
+func (c *Client) UpdateAppFrom(ctx context.Context, id string, definition io.Reader) (*Update, error) {
+	var update Update
+	path := "/v2/apps/" + escape(id) + "?force=true"
+	if e := c.call(ctx, "PUT", path, definition, &update); e != nil {
+		return nil, e
+	}
+	return &update, nil
+}
+
+// RestartApp restarts all tasks of application id.
+func (c *Client) RestartApp(ctx context.Context, id string) (*Update, error) {
+	var update Update
+	path := "/v2/apps/" + escape(id) + "/restart?force=true"
+	if e := c.call(ctx, "POST", path, nil, &update); e != nil {
+		return nil, e
+	}
+	return &update, nil
+}
+
+// DestroyApp removes application id and all of its tasks.
+func (c *Client) DestroyApp(ctx context.Context, id string) (*Update, error) {
+	var update Update
+	if e := c.call(ctx, "DELETE", "/v2/apps/"+escape(id), nil, &update); e != nil {
+		return nil, e
+	}
+	return &update, nil
+}
+
+// ListTasks returns all running tasks.
+func (c *Client) ListTasks(ctx context.Context) (*Tasks, error) {
+	var tasks Tasks
+	if e := c.call(ctx, "GET", "/v2/tasks", nil, &tasks); e != nil {
+		return nil, e
+	}
+	return &tasks, nil
+}
+
+// ListAppTasks returns the tasks of application id.
+func (c *Client) ListAppTasks(ctx context.Context, id string) ([]*Task, error) {
+	var app AppById
+	if e := c.call(ctx, "GET", "/v2/apps/"+escape(id)+"?embed=apps.tasks", nil, &app); e != nil {
+		return nil, e
+	}
+	return app.App.Tasks, nil
+}
+
+// KillTasks kills all tasks of application id.
+func (c *Client) KillTasks(ctx context.Context, id string) (*Tasks, error) {
+	var tasks Tasks
+	if e := c.call(ctx, "DELETE", "/v2/apps/"+escape(id)+"/tasks", nil, &tasks); e != nil {
+		return nil, e
+	}
+	return &tasks, nil
+}
+
+// KillTask kills task taskid of application id.
+func (c *Client) KillTask(ctx context.Context, id, taskid string) (*Task, error) {
+	var killed struct {
+		Task *Task `json:"task"`
+	}
+	path := "/v2/apps/" + escape(id) + "/tasks/" + escape(taskid)
+	if e := c.call(ctx, "DELETE", path, nil, &killed); e != nil {
+		return nil, e
+	}
+	return killed.Task, nil
+}
+
+// KillTasksAndScale kills all tasks of application id and scales it
+// down so they are not replaced.
+func (c *Client) KillTasksAndScale(ctx context.Context, id string) (*Update, error) {
+	var update Update
+	if e := c.call(ctx, "DELETE", "/v2/apps/"+escape(id)+"/tasks?scale=true", nil, &update); e != nil {
+		return nil, e
+	}
+	return &update, nil
+}
+
+// KillTaskAndScale kills task taskid of application id and scales it
+// down by one so the task is not replaced.
+func (c *Client) KillTaskAndScale(ctx context.Context, id, taskid string) (*Update, error) {
+	var update Update
+	path := "/v2/apps/" + escape(id) + "/tasks/" + escape(taskid) + "?scale=true"
+	if e := c.call(ctx, "DELETE", path, nil, &update); e != nil {
+		return nil, e
+	}
+	return &update, nil
+}
+
+// ListQueue returns the tasks waiting to be launched.
+func (c *Client) ListQueue(ctx context.Context) (*Queue, error) {
+	var queue Queue
+	if e := c.call(ctx, "GET", "/v2/queue", nil, &queue); e != nil {
+		return nil, e
+	}
+	return &queue, nil
+}
+
+// GetGroup returns group id and everything below it, or the root
+// group if id is empty.
+func (c *Client) GetGroup(ctx context.Context, id string) (*Group, error) {
+	path := "/v2/groups"
+	if id != "" {
+		path += "/" + escape(id)
+	}
+	var group Group
+	if e := c.call(ctx, "GET", path, nil, &group); e != nil {
+		return nil, e
+	}
+	return &group, nil
+}
+
+// CreateGroup deploys a new group.
+func (c *Client) CreateGroup(ctx context.Context, group *Group) (*Update, error) {
+	body, e := encode(group)
+	if e != nil {
+		return nil, e
+	}
+	return c.CreateGroupFrom(ctx, body)
+}
+
+// CreateGroupFrom deploys a new group from its JSON definition.
+func (c *Client) CreateGroupFrom(ctx context.Context, definition io.Reader) (*Update, error) {
+	var update Update
+	if e := c.call(ctx, "POST", "/v2/groups", definition, &update); e != nil {
+		return nil, e
+	}
+	return &update, nil
+}
+
+// UpdateGroup changes group id to group.
+func (c *Client) UpdateGroup(ctx context.Context, id string, group *Group) (*Update, error) {
+	body, e := encode(group)
+	if e != nil {
+		return nil, e
+	}
+	return c.UpdateGroupFrom(ctx, id, body)
+}
+
+// UpdateGroupFrom changes group id as described by the JSON definition.
+func (c *Client) UpdateGroupFrom(ctx context.Context, id string, definition io.Reader) (*Update, error) {
+	var update Update
+	if e := c.call(ctx, "PUT", "/v2/groups/"+escape(id), definition, &update); e != nil {
+		return nil, e
+	}
+	return &update, nil
+}
+
+// DestroyGroup removes group id and everything below it.
+func (c *Client) DestroyGroup(ctx context.Context, id string) (*Update, error) {
+	var update Update
+	if e := c.call(ctx, "DELETE", "/v2/groups/"+escape(id), nil, &update); e != nil {
+		return nil, e
+	}
+	return &update, nil
+}
+
+// ListDeployments returns the deployments in progress.
+func (c *Client) ListDeployments(ctx context.Context) (Deploys, error) {
+	var deploys Deploys
+	if e := c.call(ctx, "GET", "/v2/deployments", nil, &deploys); e != nil {
+		return nil, e
+	}
+	return deploys, nil
+}
+
+// WaitForDeployment blocks until deployment id is no longer in
+// progress, polling every second, or until ctx is done.
+func (c *Client) WaitForDeployment(ctx context.Context, id string) error {
+	for {
+		deploys, e := c.ListDeployments(ctx)
+		if e != nil {
+			return e
+		}
+		done := true
+		for _, deploy := range deploys {
+			if deploy.DeployID == id {
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+		if e := sleep(ctx, time.Second); e != nil {
+			return e
+		}
+	}
+}
+
+// CancelDeployment cancels deployment id, rolling back its changes.
+func (c *Client) CancelDeployment(ctx context.Context, id string) (*Update, error) {
+	var rollback Update
+	if e := c.call(ctx, "DELETE", "/v2/deployments/"+escape(id), nil, &rollback); e != nil {
+		return nil, e
+	}
+	return &rollback, nil
+}
+
+// GetLeader returns the current Marathon leader.
+func (c *Client) GetLeader(ctx context.Context) (*Which, error) {
+	var which Which
+	if e := c.call(ctx, "GET", "/v2/leader", nil, &which); e != nil {
+		return nil, e
+	}
+	return &which, nil
+}
+
+// Abdicate forces the current leader to relinquish control.
+func (c *Client) Abdicate(ctx context.Context) (*Message, error) {
+	var message Message
+	if e := c.call(ctx, "DELETE", "/v2/leader", nil, &message); e != nil {
+		return nil, e
+	}
+	return &message, nil
+}
+
+// Ping returns how long host took to answer /ping.
+func (c *Client) Ping(ctx context.Context, host string) (time.Duration, error) {
+	request, e := c.request(ctx, "GET", "/ping", nil)
+	if e != nil {
+		return 0, e
+	}
+	start := time.Now()
+	response, e := c.doHost(request, host)
+	if e != nil {
+		return 0, e
+	}
+	response.Body.Close()
+	return time.Now().Sub(start), nil
+}
+
+// UploadArtifact stores the content of r in the artifact store at
+// path and returns its location.
+func (c *Client) UploadArtifact(ctx context.Context, path string, r io.Reader) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, e := writer.CreateFormFile("file", filepath.Base(path))
+	if e != nil {
+		return "", fmt.Errorf("failed to create form file: %v", e)
+	}
+	if _, e := io.Copy(part, r); e != nil {
+		return "", fmt.Errorf("failed to get file bytes: %v", e)
+	}
+	if e := writer.Close(); e != nil {
+		return "", fmt.Errorf("failed to close form: %v", e)
+	}
+
+	request, e := c.request(ctx, "POST", "/v2/artifacts"+escape(path), body)
+	if e != nil {
+		return "", e
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	response, e := c.Do(request)
+	if e != nil {
+		return "", e
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated {
+		b, _ := ioutil.ReadAll(response.Body)
+		return "", newAPIError("POST", "/v2/artifacts"+path, response.StatusCode, b)
+	}
+	return response.Header.Get("Location"), nil
+}
+
+// GetArtifact returns the content of the artifact at path.
+func (c *Client) GetArtifact(ctx context.Context, path string) ([]byte, error) {
+	var content []byte
+	if e := c.call(withRaw(ctx, &content), "GET", "/v2/artifacts"+escape(path), nil, nil); e != nil {
+		return nil, e
+	}
+	return content, nil
+}
+
+// DeleteArtifact removes the artifact at path.
+func (c *Client) DeleteArtifact(ctx context.Context, path string) error {
+	return c.call(ctx, "DELETE", "/v2/artifacts"+escape(path), nil, nil)
+}
+
+// trimHost returns the URL of r without the placeholder host.
+func trimHost(r *http.Request) string {
+	return strings.TrimPrefix(r.URL.String(), "HOST")
+}
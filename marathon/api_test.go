@@ -0,0 +1,36 @@
+package marathon
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUpdateAppSendsFieldsSet(t *testing.T) {
+	h := newHost(t, "")
+	c := testClient(t, true, h)
+	app := &Application{
+		ID:           "/a",
+		Cmd:          "run a",
+		Mem:          64,
+		Version:      "2015-04-07T20:29:35.672Z",
+		Tasks:        []*Task{{ID: "a.1"}},
+		TasksRunning: 1,
+	}
+	if _, e := c.UpdateApp(context.Background(), "/a", app); e != nil {
+		t.Fatal(e)
+	}
+	want := `{"id":"/a","cmd":"run a","mem":64}`
+	checkBodies(t, h, []byte(want), 1)
+	if app.Version == "" || app.Tasks == nil {
+		t.Error("the app given was changed")
+	}
+}
+
+func TestUpdateAppKeepsInstances(t *testing.T) {
+	h := newHost(t, "")
+	c := testClient(t, true, h)
+	if _, e := c.UpdateApp(context.Background(), "/a", &Application{Mem: 128}); e != nil {
+		t.Fatal(e)
+	}
+	checkBodies(t, h, []byte(`{"mem":128}`), 1)
+}
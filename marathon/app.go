@@ -4,14 +4,13 @@ package marathon
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/url"
 	"os"
 	"strconv"
-	"strings"
 
 	"github.com/layneYoo/mCtl/check"
 )
@@ -21,32 +20,37 @@ type AppList struct {
 	Formats Formatter
 }
 
-func (a AppList) Apply(args []string) {
-	path := "/v2/apps"
-	request := a.Clients.GET(path)
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-	fmt.Println(a.Formats.Format(response.Body, a.Humanize))
+func (a AppList) Flags() *flag.FlagSet {
+	return listFlagSet("app list", &listFlags{})
 }
 
-func (a AppList) Humanize(body io.Reader) string {
+func (a AppList) Apply(ctx context.Context, args []string) {
+	args, chosen := listFlagsOf("app list", args)
+	check.Check(len(args) == 0, "no arguments")
+	var raw []byte
+	applications, e := a.Clients.ListApps(withRaw(ctx, &raw))
+	a.Formats.Check(e, "failed to list apps")
+	raw, chosenApps := chosen.choose(raw, applications, "apps")
+	fmt.Println(a.Formats.For(chosenApps).FormatTable(bytes.NewReader(raw), a.Tabulate))
+}
+
+func (a AppList) Usage() []Usage {
+	return []Usage{
+		{"", "list all apps"},
+	}
+}
+
+func (a AppList) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var applications Applications
 	e := dec.Decode(&applications)
 	check.Check(e == nil, "failed to unmarshal response", e)
-	title := "APP VERSION USER\n"
-	var b bytes.Buffer
+	t := newTable("ID", "VERSION", "USER")
+	t.wide("INSTANCES", "CPUS", "MEM", "CMD")
 	for _, app := range applications.Apps {
-		b.WriteString(app.ID)
-		b.WriteString(" ")
-		b.WriteString(app.Version)
-		b.WriteString(" ")
-		b.WriteString(app.User)
-		b.WriteString("\n")
+		t.add(app.ID, app.Version, app.User, strconv.Itoa(app.Instances), formatFloat(app.CPUs), formatFloat(app.Mem), app.Cmd)
 	}
-	text := title + b.String()
-	return Columnize(text)
+	return t
 }
 
 type AppVersions struct {
@@ -54,29 +58,37 @@ type AppVersions struct {
 	Formats Formatter
 }
 
-func (a AppVersions) Apply(args []string) {
+func (a AppVersions) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) > 0, "must supply id")
-	id := url.QueryEscape(args[0])
-	path := "/v2/apps/" + id + "/versions"
-	request := a.Clients.GET(path)
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "failed to list verions", e)
-	defer response.Body.Close()
-	fmt.Println(a.Formats.Format(response.Body, a.Humanize))
+	var raw []byte
+	versions, e := a.Clients.ListAppVersions(withRaw(ctx, &raw), args[0])
+	a.Formats.Check(e, "failed to list verions")
+	fmt.Println(a.Formats.For(versions).FormatTable(bytes.NewReader(raw), a.Tabulate))
+}
+
+func (a AppVersions) Usage() []Usage {
+	return []Usage{
+		{"[id]", "list all versions of app id"},
+	}
+}
+
+func (a AppVersions) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return a.Clients.appIDs(ctx)
+	}
+	return nil
 }
 
-func (a AppVersions) Humanize(body io.Reader) string {
+func (a AppVersions) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var versions Versions
 	e := dec.Decode(&versions)
 	check.Check(e == nil, "failed to unmarshal response", e)
-	var b bytes.Buffer
-	b.WriteString("VERSIONS\n")
+	t := newTable("VERSIONS")
 	for _, version := range versions.Versions {
-		b.WriteString(version)
-		b.WriteString("\n")
+		t.add(version)
 	}
-	return b.String()
+	return t
 }
 
 type AppShow struct {
@@ -84,60 +96,66 @@ type AppShow struct {
 	Formats Formatter
 }
 
-func (a AppShow) Apply(args []string) {
-	path := ""
-	fn := a.HumanizeById
+func (a AppShow) Apply(ctx context.Context, args []string) {
+	var raw []byte
+	ctx = withRaw(ctx, &raw)
+	var application *Application
+	var e error
+	fn := a.TabulateById
 	switch len(args) {
 	case 1:
-		id := url.QueryEscape(args[0])
-		path = "/v2/apps/" + id
+		application, e = a.Clients.GetApp(ctx, args[0])
 	case 2:
-		id := url.QueryEscape(args[0])
-		version := url.QueryEscape(args[1])
-		path = "/v2/apps/" + id + "/versions/" + version
-		fn = a.Humanize
+		application, e = a.Clients.GetAppVersion(ctx, args[0], args[1])
+		fn = a.Tabulate
 	default:
 		check.Check(false, "must provide id and/or version")
 	}
-	request := a.Clients.GET(path)
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "failed to show app", e)
-	defer response.Body.Close()
-	fmt.Println(a.Formats.Format(response.Body, fn))
+	a.Formats.Check(e, "failed to show app")
+	fmt.Println(a.Formats.For(application).FormatTable(bytes.NewReader(raw), fn))
+}
+
+func (a AppShow) Usage() []Usage {
+	return []Usage{
+		{"[id]", "show config and status of app id (latest version)"},
+		{"[id] [version]", "show config and status of app id at version"},
+	}
 }
 
-func (a AppShow) HumanizeById(body io.Reader) string {
+func (a AppShow) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return a.Clients.appIDs(ctx)
+	}
+	return nil
+}
+
+func (a AppShow) TabulateById(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var appbyid AppById
 	e := dec.Decode(&appbyid)
 	check.Check(e == nil, "failed to unmarshal response", e)
-	title := "INSTANCES MEM CMD\n"
-	var b bytes.Buffer
-	b.WriteString(strconv.Itoa(appbyid.App.Instances))
-	b.WriteString(" ")
-	mem := fmt.Sprintf("%.2f", appbyid.App.Mem)
-	b.WriteString(mem)
-	b.WriteString(" ")
-	b.WriteString(appbyid.App.Cmd)
-	text := title + b.String()
-	return Columnize(text)
-}
-
-func (a AppShow) Humanize(body io.Reader) string {
+	return tabulateApp(appbyid.App)
+}
+
+func (a AppShow) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var application Application
 	e := dec.Decode(&application)
 	check.Check(e == nil, "failed to unmarshal response", e)
-	title := "INSTANCES MEM CMD\n"
-	var b bytes.Buffer
-	b.WriteString(strconv.Itoa(application.Instances))
-	b.WriteString(" ")
-	mem := fmt.Sprintf("%.2f", application.Mem)
-	b.WriteString(mem)
-	b.WriteString(" ")
-	b.WriteString(application.Cmd)
-	text := title + b.String()
-	return Columnize(text)
+	return tabulateApp(application)
+}
+
+func tabulateApp(app Application) Table {
+	t := newTable("INSTANCES", "MEM", "CMD")
+	t.wide("ID", "VERSION", "CPUS", "RUNNING", "STAGED")
+	t.add(strconv.Itoa(app.Instances), fmt.Sprintf("%.2f", app.Mem), app.Cmd,
+		app.ID, app.Version, formatFloat(app.CPUs), strconv.Itoa(app.TasksRunning), strconv.Itoa(app.TasksStaged))
+	return t
+}
+
+// formatFloat formats resources such as cpus without needless zeros.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
 }
 
 type AppCreate struct {
@@ -145,31 +163,31 @@ type AppCreate struct {
 	Formats Formatter
 }
 
-func (a AppCreate) Apply(args []string) {
+func (a AppCreate) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) == 1, "must specifiy 1 jsonfile")
 	f, e := os.Open(args[0])
 	check.Check(e == nil, "failed to open jsonfile", e)
 	defer f.Close()
-	request := a.Clients.POST("/v2/apps", f)
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-	check.Check(response.StatusCode != 409, "app already exists")
-	check.Check(response.StatusCode != 422, "app id is invalid")
-	fmt.Println(a.Formats.Format(response.Body, a.Humanize))
+	var raw []byte
+	application, e := a.Clients.CreateAppFrom(withRaw(ctx, &raw), f)
+	a.Formats.Check(e, "failed to create app")
+	fmt.Println(a.Formats.For(application).FormatTable(bytes.NewReader(raw), a.Tabulate))
 }
 
-func (a AppCreate) Humanize(body io.Reader) string {
+func (a AppCreate) Usage() []Usage {
+	return []Usage{
+		{"[jsonfile]", "deploy application defined in jsonfile"},
+	}
+}
+
+func (a AppCreate) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var application Application
 	e := dec.Decode(&application)
 	check.Check(e == nil, "failed to decode response", e)
-	var b bytes.Buffer
-	b.WriteString("APPID VERSION\n")
-	b.WriteString(application.ID)
-	b.WriteString(" ")
-	b.WriteString(application.Version)
-	return Columnize(b.String())
+	t := newTable("APPID", "VERSION")
+	t.add(application.ID, application.Version)
+	return t
 }
 
 type AppUpdate struct {
@@ -177,20 +195,69 @@ type AppUpdate struct {
 	Formats Formatter
 }
 
-func (a AppUpdate) Apply(args []string) {
+// updateFlags are the changes app update makes without a jsonfile;
+// a negative value leaves the setting alone.
+type updateFlags struct {
+	instances int
+	cpus, mem float64
+	wait      bool
+}
+
+func (a AppUpdate) flags(f *updateFlags) *flag.FlagSet {
+	fs := newFlagSet("app update")
+	fs.IntVar(&f.instances, "instances", -1, "scale to `N` instances")
+	fs.Float64Var(&f.cpus, "cpus", -1, "set the cpu share to `cpus`")
+	fs.Float64Var(&f.mem, "mem", -1, "set the memory to `MB`")
+	fs.BoolVar(&f.wait, "wait", false, "wait for the deployment to finish")
+	return fs
+}
+
+func (a AppUpdate) Flags() *flag.FlagSet {
+	return a.flags(&updateFlags{})
+}
+
+func (a AppUpdate) Apply(ctx context.Context, args []string) {
+	var f updateFlags
+	args = flagsOf(a.flags(&f), args)
+	if f.body() != nil {
+		check.Check(len(args) == 1, "app update with flags takes 1 app id")
+		a.update(ctx, args[0], f.body(), f.wait)
+		return
+	}
 	switch len(args) {
 	case 1:
-		a.fromJsonBody(args)
+		a.fromJsonBody(ctx, args, f.wait)
 	case 2:
-		a.fromJson(args)
+		a.fromJson(ctx, args, f.wait)
 	case 3:
-		a.fromCLI(args)
+		a.fromCLI(ctx, args, f.wait)
 	default:
 		check.Check(false, "app update 1, 2 or 3 arguments required")
 	}
 }
 
-func (a AppUpdate) fromJsonBody(args []string) {
+func (a AppUpdate) Usage() []Usage {
+	return []Usage{
+		{"[jsonfile]", "update application as defined in jsonfile"},
+		{"[id] [jsonfile]", "update application id as defined in jsonfile"},
+		{"[id] [flags]", "update application id as set by the flags"},
+		{"cpu [id] [cpu%]", "update application id to have cpu% of cpu share"},
+		{"memory [id] [MB]", "update application id to have MB of memory"},
+		{"instances [id] [N]", "update application id to have N instances"},
+	}
+}
+
+func (a AppUpdate) Complete(ctx context.Context, args []string) []string {
+	switch {
+	case len(args) == 0:
+		return append(a.Clients.appIDs(ctx), "cpu", "memory", "instances")
+	case len(args) == 1 && contains([]string{"cpu", "memory", "instances"}, args[0]):
+		return a.Clients.appIDs(ctx)
+	}
+	return nil
+}
+
+func (a AppUpdate) fromJsonBody(ctx context.Context, args []string, wait bool) {
 	f, e := os.Open(args[0])
 	check.Check(e == nil, "failed to open jsonfile", e)
 	defer f.Close()
@@ -200,57 +267,80 @@ func (a AppUpdate) fromJsonBody(args []string) {
 	derr := dec.Decode(&application)
 	check.Check(derr == nil, "failed to unmarshal response", derr)
 	f.Seek(0, 0)
-	a.update(application.ID, f)
-
+	a.update(ctx, application.ID, f, wait)
 }
 
-func (a AppUpdate) fromJson(args []string) {
-	id := url.QueryEscape(args[0])
+func (a AppUpdate) fromJson(ctx context.Context, args []string, wait bool) {
+	id := args[0]
 	f, e := os.Open(args[1])
 	check.Check(e == nil, "failed to open jsonfile", e)
 	defer f.Close()
-	a.update(id, f)
+	a.update(ctx, id, f, wait)
 }
 
-func (a AppUpdate) fromCLI(args []string) {
-	val, e := strconv.ParseFloat(args[2], 64)
-	check.Check(e == nil, "valid number required", args[1])
-	var body string
+// fromCLI is the positional form of the flags, as in
+// "instances /x 3".
+func (a AppUpdate) fromCLI(ctx context.Context, args []string, wait bool) {
+	f := updateFlags{instances: -1, cpus: -1, mem: -1}
 	switch args[0] {
 	case "instances":
-		body = fmt.Sprintf("{\"instances\": %f}", val)
-	case "memory":
-		fallthrough
-	case "mem":
-		body = fmt.Sprintf("{\"mem\": %f}", val)
-	case "cpu":
-		body = fmt.Sprintf("{\"cpus\": %f}", val)
+		n, e := strconv.Atoi(args[2])
+		check.Check(e == nil, "valid number required", args[2])
+		f.instances = n
+	case "memory", "mem", "cpu":
+		val, e := strconv.ParseFloat(args[2], 64)
+		check.Check(e == nil, "valid number required", args[2])
+		if args[0] == "cpu" {
+			f.cpus = val
+		} else {
+			f.mem = val
+		}
 	default:
 		check.Check(false, "unknown update option", args[0])
 	}
-	id := url.QueryEscape(args[1])
-	a.update(id, ioutil.NopCloser(strings.NewReader(body)))
+	a.update(ctx, args[1], f.body(), wait)
+}
+
+// body returns the JSON changing the settings given in f, or nil if
+// there are none.
+func (f updateFlags) body() io.Reader {
+	changes := map[string]interface{}{}
+	if f.instances >= 0 {
+		changes["instances"] = f.instances
+	}
+	if f.cpus >= 0 {
+		changes["cpus"] = f.cpus
+	}
+	if f.mem >= 0 {
+		changes["mem"] = f.mem
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(changes)
+	return bytes.NewReader(b)
 }
 
-func (a AppUpdate) update(id string, body io.ReadCloser) {
-	url := "/v2/apps/" + id + "?force=true"
-	request := a.Clients.PUT(url, body)
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-	sc := response.StatusCode
-	check.Check(sc == 200, "bad status code", sc)
-	fmt.Println(a.Formats.Format(response.Body, a.Humanize))
+func (a AppUpdate) update(ctx context.Context, id string, body io.Reader, wait bool) {
+	var raw []byte
+	update, e := a.Clients.UpdateAppFrom(withRaw(ctx, &raw), id, body)
+	a.Formats.Check(e, "failed to update app")
+	if wait {
+		a.Formats.Check(a.Clients.WaitForDeployment(ctx, update.DeploymentID), "failed to wait for deployment")
+	}
+	fmt.Println(a.Formats.For(update).FormatTable(bytes.NewReader(raw), tabulateUpdate))
 }
 
-func (a AppUpdate) Humanize(body io.Reader) string {
+// tabulateUpdate is the Tabulate of the deployments started by
+// changes to apps and groups.
+func tabulateUpdate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var update Update
 	e := dec.Decode(&update)
 	check.Check(e == nil, "failed to decode response", e)
-	title := "DEPLOYID VERSION\n"
-	text := title + update.DeploymentID + " " + update.Version
-	return Columnize(text)
+	t := newTable("DEPLOYID", "VERSION")
+	t.add(update.DeploymentID, update.Version)
+	return t
 }
 
 type AppRestart struct {
@@ -258,24 +348,40 @@ type AppRestart struct {
 	Formats Formatter
 }
 
-func (a AppRestart) Apply(args []string) {
+func (a AppRestart) flags(wait *bool) *flag.FlagSet {
+	fs := newFlagSet("app restart")
+	fs.BoolVar(wait, "wait", false, "wait for the deployment to finish")
+	return fs
+}
+
+func (a AppRestart) Flags() *flag.FlagSet {
+	return a.flags(new(bool))
+}
+
+func (a AppRestart) Apply(ctx context.Context, args []string) {
+	var wait bool
+	args = flagsOf(a.flags(&wait), args)
 	check.Check(len(args) == 1, "specify 1 app id to restart")
-	id := url.QueryEscape(args[0])
-	request := a.Clients.POST("/v2/apps/"+id+"/restart?force=true", nil)
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-	fmt.Println(a.Formats.Format(response.Body, a.Humanize))
+	var raw []byte
+	update, e := a.Clients.RestartApp(withRaw(ctx, &raw), args[0])
+	a.Formats.Check(e, "failed to restart app")
+	if wait {
+		a.Formats.Check(a.Clients.WaitForDeployment(ctx, update.DeploymentID), "failed to wait for deployment")
+	}
+	fmt.Println(a.Formats.For(update).FormatTable(bytes.NewReader(raw), tabulateUpdate))
 }
 
-func (a AppRestart) Humanize(body io.Reader) string {
-	dec := json.NewDecoder(body)
-	var update Update
-	e := dec.Decode(&update)
-	check.Check(e == nil, "failed to decode response", e)
-	title := "DEPLOYID VERSION\n"
-	text := title + update.DeploymentID + " " + update.Version
-	return Columnize(text)
+func (a AppRestart) Usage() []Usage {
+	return []Usage{
+		{"[id]", "restart app id"},
+	}
+}
+
+func (a AppRestart) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return a.Clients.appIDs(ctx)
+	}
+	return nil
 }
 
 type AppDestroy struct {
@@ -283,17 +389,40 @@ type AppDestroy struct {
 	Formats Formatter
 }
 
-func (a AppDestroy) Apply(args []string) {
+func (a AppDestroy) flags(wait *bool) *flag.FlagSet {
+	fs := newFlagSet("app destroy")
+	fs.BoolVar(wait, "wait", false, "wait until the app is gone")
+	return fs
+}
+
+func (a AppDestroy) Flags() *flag.FlagSet {
+	return a.flags(new(bool))
+}
+
+func (a AppDestroy) Apply(ctx context.Context, args []string) {
+	var wait bool
+	args = flagsOf(a.flags(&wait), args)
 	check.Check(len(args) == 1, "must specify id")
-	path := "/v2/apps/" + url.QueryEscape(args[0])
-	request := a.Clients.DELETE(path)
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "destroy app failed", e)
-	c := response.StatusCode
-	// documentation says this is 204, wtf
-	check.Check(c == 200, "destroy app bad status", c)
-	defer response.Body.Close()
-	fmt.Println(a.Formats.Format(response.Body, a.Humanize))
+	var raw []byte
+	update, e := a.Clients.DestroyApp(withRaw(ctx, &raw), args[0])
+	a.Formats.Check(e, "destroy app failed")
+	if wait {
+		a.Formats.Check(a.Clients.WaitForDeployment(ctx, update.DeploymentID), "failed to wait for deployment")
+	}
+	fmt.Println(a.Formats.For(update).Format(bytes.NewReader(raw), a.Humanize))
+}
+
+func (a AppDestroy) Usage() []Usage {
+	return []Usage{
+		{"[id]", "destroy and remove all instances of app id"},
+	}
+}
+
+func (a AppDestroy) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return a.Clients.appIDs(ctx)
+	}
+	return nil
 }
 
 func (a AppDestroy) Humanize(body io.Reader) string {
@@ -4,14 +4,11 @@ package marathon
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
-	"net/http"
-	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/layneYoo/mCtl/check"
@@ -23,41 +20,34 @@ type ArtifactUpload struct {
 	Formats Formatter
 }
 
-func (a ArtifactUpload) Apply(args []string) {
+func (a ArtifactUpload) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) == 2, "must supply path and file")
 
 	path := args[0]
 	filename := args[1]
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	part, e := writer.CreateFormFile("file", filepath.Base(path))
-	check.Check(e == nil, "failed to create form file", e)
 	f, e := os.Open(filename)
 	check.Check(e == nil, "failed to open file "+filename, e)
-	_, e = io.Copy(part, f)
-	check.Check(e == nil, "failed to get file bytes", e)
-	e = writer.Close()
-	check.Check(e == nil, "failed to close file", e)
-
-	request := a.Clients.POST("/v2/artifacts"+url.QueryEscape(path), body)
-	request.Header.Set("Content-Type", writer.FormDataContentType())
+	defer f.Close()
 
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
+	location, e := a.Clients.UploadArtifact(ctx, path, f)
+	a.Formats.Check(e, "unable to upload file")
 
-	check.Check(response.StatusCode == 201, "unable to upload file", stringifyResponse(response))
-	defer response.Body.Close()
+	fmt.Println(a.Formats.FormatTable(strings.NewReader(location), a.Tabulate))
+}
 
-	fmt.Println(a.Formats.Format(strings.NewReader(response.Header.Get("Location")), a.Humanize))
+func (a ArtifactUpload) Usage() []Usage {
+	return []Usage{
+		{"[path] [file]", "upload file to the artifact store at path"},
+	}
 }
 
-func (P ArtifactUpload) Humanize(body io.Reader) string {
+func (a ArtifactUpload) Tabulate(body io.Reader) Table {
 	b, e := ioutil.ReadAll(body)
 	check.Check(e == nil, "reading upload response failed", e)
-	text := "LOCATION\n" + string(b)
-	return Columnize(text)
+	t := newTable("LOCATION")
+	t.add(string(b))
+	return t
 }
 
 // get
@@ -66,46 +56,39 @@ type ArtifactGet struct {
 	Formats Formatter
 }
 
-func (a ArtifactGet) Apply(args []string) {
+func (a ArtifactGet) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) == 1, "must supply id")
-	request := a.Clients.GET("/v2/artifacts" + url.QueryEscape(args[0]))
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	check.Check(response.StatusCode != 404, "artifact not found")
-	check.Check(response.StatusCode == 200, "error downloading artifact", stringifyResponse(response))
-	defer response.Body.Close()
-
-	b, e := ioutil.ReadAll(response.Body)
+	b, e := a.Clients.GetArtifact(ctx, args[0])
+	a.Formats.Check(e, "error downloading artifact")
 	os.Stdout.Write(b)
 }
 
+func (a ArtifactGet) Usage() []Usage {
+	return []Usage{
+		{"[path]", "get artifact at path from the store"},
+	}
+}
+
 // delete
 type ArtifactDelete struct {
 	Clients *Client
 	Formats Formatter
 }
 
-func (a ArtifactDelete) Apply(args []string) {
+func (a ArtifactDelete) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) == 1, "must supply id")
-	request := a.Clients.DELETE("/v2/artifacts" + url.QueryEscape(args[0]))
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "failed to delete artifact", e)
-	check.Check(response.StatusCode == 200, "failed to delete artifact", stringifyResponse(response))
-	defer response.Body.Close()
+	var raw []byte
+	e := a.Clients.DeleteArtifact(withRaw(ctx, &raw), args[0])
+	a.Formats.Check(e, "failed to delete artifact")
+	fmt.Println(a.Formats.Format(bytes.NewReader(raw), a.Humanize))
+}
 
-	fmt.Println(a.Formats.Format(response.Body, a.Humanize))
+func (a ArtifactDelete) Usage() []Usage {
+	return []Usage{
+		{"[path]", "delete artifact at path from the store"},
+	}
 }
 
 func (a ArtifactDelete) Humanize(body io.Reader) string {
 	return "DELETED"
 }
-
-func stringifyResponse(res *http.Response) string {
-	cnt, err := ioutil.ReadAll(res.Body)
-
-	if err != nil {
-		return fmt.Sprintf("%v", err)
-	}
-
-	return string(cnt)
-}
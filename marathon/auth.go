@@ -0,0 +1,319 @@
+package marathon
+
+// Authentication of requests, and the actions under command auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// AuthOptions select how requests are authenticated. The first of
+// Token, TokenFile, TokenCommand and LoginURL that is set is used,
+// otherwise the user and password of Login are sent as basic auth.
+type AuthOptions struct {
+	Token        string // static token
+	TokenFile    string // file containing the token
+	TokenCommand string // command printing the token on stdout
+	LoginURL     string // DC/OS ACS login endpoint exchanging user and password for a token
+}
+
+// An Authenticator adds credentials to requests.
+type Authenticator interface {
+	// Apply sets the Authorization header of r.
+	Apply(r *http.Request) error
+
+	// Invalidate is called when Marathon rejected the credentials
+	// with 401, it returns true if new credentials were obtained
+	// and the request is worth sending again.
+	Invalidate() bool
+}
+
+func (o AuthOptions) authenticator(login *Login, client *http.Client) Authenticator {
+	switch {
+	case o.Token != "":
+		return staticToken(o.Token)
+	case o.TokenFile != "":
+		return fileToken(o.TokenFile)
+	case o.TokenCommand != "":
+		return &commandToken{command: o.TokenCommand}
+	case o.LoginURL != "":
+		return &acsToken{
+			url:    o.LoginURL,
+			login:  login,
+			client: client,
+		}
+	}
+	return basicAuth{login}
+}
+
+func setToken(r *http.Request, token string) {
+	r.Header.Set("Authorization", "token="+token)
+}
+
+// basicAuth sends user and password, if any
+type basicAuth struct {
+	login *Login
+}
+
+func (b basicAuth) Apply(r *http.Request) error {
+	pass, e := b.login.Password()
+	if e != nil {
+		return e
+	}
+	if b.login.User != "" && pass != "" {
+		r.SetBasicAuth(b.login.User, pass)
+	}
+	return nil
+}
+
+func (b basicAuth) Invalidate() bool {
+	return false
+}
+
+// staticToken is a token given in the configuration
+type staticToken string
+
+func (t staticToken) Apply(r *http.Request) error {
+	setToken(r, string(t))
+	return nil
+}
+
+func (t staticToken) Invalidate() bool {
+	return false
+}
+
+// fileToken is a token read from a file for each request, so that
+// whatever keeps the file up to date is always honored
+type fileToken string
+
+func (t fileToken) Apply(r *http.Request) error {
+	b, e := ioutil.ReadFile(string(t))
+	if e != nil {
+		return fmt.Errorf("failed to read token file: %v", e)
+	}
+	setToken(r, strings.TrimSpace(string(b)))
+	return nil
+}
+
+func (t fileToken) Invalidate() bool {
+	return false
+}
+
+// commandToken is a token printed by a command, which is run again
+// when Marathon rejects the token
+type commandToken struct {
+	command string
+	token   string
+}
+
+func (t *commandToken) Apply(r *http.Request) error {
+	if t.token == "" {
+		out, e := exec.Command("sh", "-c", t.command).Output()
+		if e != nil {
+			return fmt.Errorf("token command failed: %v", e)
+		}
+		t.token = strings.TrimSpace(string(out))
+	}
+	setToken(r, t.token)
+	return nil
+}
+
+func (t *commandToken) Invalidate() bool {
+	rerun := t.token != ""
+	t.token = ""
+	return rerun
+}
+
+// acsToken is a token obtained from a DC/OS ACS login endpoint, it
+// is cached on disk until it expires
+type acsToken struct {
+	url    string
+	login  *Login
+	client *http.Client
+	cached *cachedToken
+}
+
+type cachedToken struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+func (c *cachedToken) valid() bool {
+	return c != nil && c.Token != "" && (c.Expires.IsZero() || time.Now().Before(c.Expires))
+}
+
+func (t *acsToken) Apply(r *http.Request) error {
+	if t.cached == nil {
+		t.cached = loadToken(t.key())
+	}
+	if !t.cached.valid() {
+		if _, e := t.login.Password(); e != nil {
+			return e
+		}
+		if !t.login.NeedsAuth() {
+			return errors.New("not logged in, run marathonctl auth login")
+		}
+		if e := t.Login(); e != nil {
+			return e
+		}
+	}
+	setToken(r, t.cached.Token)
+	return nil
+}
+
+func (t *acsToken) Invalidate() bool {
+	t.cached = nil
+	saveToken(t.key(), nil)
+	return t.login.NeedsAuth() && t.Login() == nil
+}
+
+// key identifies the cached token of this user at this endpoint.
+func (t *acsToken) key() string {
+	return t.login.User + "@" + t.url
+}
+
+// Login exchanges user and password for a new token and caches it.
+func (t *acsToken) Login() error {
+	pass, e := t.login.Password()
+	if e != nil {
+		return e
+	}
+	body, _ := json.Marshal(map[string]string{
+		"uid":      t.login.User,
+		"password": pass,
+	})
+	response, e := t.client.Post(t.url, "application/json", bytes.NewReader(body))
+	if e != nil {
+		return fmt.Errorf("%w: login request failed: %s", ErrUnreachable, describe(e))
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return fmt.Errorf("%w: login failed: %s", ErrAuth, response.Status)
+	}
+	var answer struct {
+		Token string `json:"token"`
+	}
+	if e := json.NewDecoder(response.Body).Decode(&answer); e != nil || answer.Token == "" {
+		return errors.New("login response did not contain a token")
+	}
+	t.cached = &cachedToken{
+		Token:   answer.Token,
+		Expires: expiry(answer.Token),
+	}
+	saveToken(t.key(), t.cached)
+	return nil
+}
+
+// expiry returns the exp claim of a JWT, or the zero time if the
+// token is not a JWT, in which case it is used until rejected.
+func expiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, e := base64.RawURLEncoding.DecodeString(parts[1])
+	if e != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+func tokenFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "marathonctl", "tokens")
+}
+
+func loadTokens() map[string]*cachedToken {
+	tokens := make(map[string]*cachedToken)
+	if b, e := ioutil.ReadFile(tokenFile()); e == nil {
+		json.Unmarshal(b, &tokens)
+	}
+	return tokens
+}
+
+func loadToken(key string) *cachedToken {
+	return loadTokens()[key]
+}
+
+// saveToken stores token under key, or removes key if token is nil.
+// Failing to write the cache is not fatal, it only means logging in
+// again next time.
+func saveToken(key string, token *cachedToken) {
+	tokens := loadTokens()
+	if token == nil {
+		delete(tokens, key)
+	} else {
+		tokens[key] = token
+	}
+	b, e := json.Marshal(tokens)
+	if e != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(tokenFile()), 0700)
+	ioutil.WriteFile(tokenFile(), b, 0600)
+}
+
+// login
+type AuthLogin struct {
+	Clients *Client
+	Formats Formatter
+}
+
+func (a AuthLogin) Apply(ctx context.Context, args []string) {
+	check.Check(len(args) == 0, "no arguments")
+	acs, ok := a.Clients.auth.(*acsToken)
+	check.Check(ok, "auth login requires marathon.login_url")
+	_, e := acs.login.Password()
+	a.Formats.Check(e, "auth login failed")
+	check.Check(acs.login.NeedsAuth(), "auth login requires user and password")
+	e = acs.Login()
+	a.Formats.Check(e, "auth login failed")
+	b, e := json.Marshal(map[string]interface{}{
+		"uid":     acs.login.User,
+		"expires": acs.cached.Expires,
+	})
+	check.Check(e == nil, "failed to encode response", e)
+	fmt.Println(a.Formats.FormatTable(bytes.NewReader(b), a.Tabulate))
+}
+
+func (a AuthLogin) Usage() []Usage {
+	return []Usage{
+		{"", "exchange user and password for a token at -login-url"},
+	}
+}
+
+func (a AuthLogin) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var login struct {
+		UID     string    `json:"uid"`
+		Expires time.Time `json:"expires"`
+	}
+	e := dec.Decode(&login)
+	check.Check(e == nil, "failed to decode response", e)
+	var expires string
+	if !login.Expires.IsZero() {
+		expires = login.Expires.Format(time.RFC3339)
+	}
+	t := newTable("USER", "EXPIRES")
+	t.add(login.UID, expires)
+	return t
+}
@@ -1,22 +1,33 @@
 package marathon
 
 import (
+	"context"
+
 	"github.com/layneYoo/mCtl/check"
 )
 
 type Selector interface {
-	Select(args []string)
+	Select(ctx context.Context, args []string)
 }
 
 type Category struct {
 	Actions map[string]Action
+	Summary string // what the actions are about, for help
+
+	// Local categories do not talk to Marathon, and so work without
+	// any host configured.
+	Local bool
 }
 
-func (c Category) Select(args []string) {
+func (c Category) Select(ctx context.Context, args []string) {
 	check.Check(len(args) > 0, "must specify sub-action")
-	if action, ok := c.Actions[args[0]]; !ok {
-		check.Usage()
-	} else {
-		action.Apply(args[1:])
+	action, ok := c.Actions[args[0]]
+	check.Check(ok, "unknown action", args[0])
+	args = args[1:]
+	if _, ok := action.(flagged); !ok && len(args) > 0 && args[0] == "--" {
+		// "--" only stops --help being taken as asking for help,
+		// actions with flags drop it when parsing them
+		args = args[1:]
 	}
+	action.Apply(ctx, args)
 }
@@ -1,136 +1,483 @@
 package marathon
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/layneYoo/mCtl/check"
 )
 
 type Action interface {
-	// Apply runs the command with args and gets the json result.
-	Apply(args []string)
+	// Apply runs the command with args and gets the json result,
+	// giving up when ctx is done.
+	Apply(ctx context.Context, args []string)
+
+	// Usage describes the forms of the command, for help.
+	Usage() []Usage
 }
 
 type Login struct {
 	Hosts []string
 	User  string
 	Pass  string
+
+	// Secrets supply the password when Pass is not set, the first
+	// one with a password is used.
+	Secrets []Secret
+
+	// RoundRobin disables leader discovery, every request is sent
+	// to the first host in Hosts that answers.
+	RoundRobin bool
+
+	lock sync.Mutex
 }
 
 func (l *Login) NeedsAuth() bool {
 	// Auth is only needed if User and Pass were set
-	return l.User != "" && l.Pass != ""
+	pass, _ := l.Password()
+	return l.User != "" && pass != ""
 }
 
-func NewLogin(hosts, login string) *Login {
+// Password returns Pass, looking it up in Secrets the first time
+// if it is not set.
+func (l *Login) Password() (string, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.Pass != "" || l.User == "" {
+		return l.Pass, nil
+	}
+	for _, secret := range l.Secrets {
+		pass, e := secret()
+		if e != nil {
+			return "", e
+		}
+		if pass != "" {
+			l.Pass = pass
+			break
+		}
+	}
+	l.Secrets = nil
+	return l.Pass, nil
+}
+
+// NewLogin creates a Login for the comma separated hosts, login is
+// "user:password" or just "user" when secrets supply the password.
+func NewLogin(hosts, login string, secrets ...Secret) *Login {
 	hostlist := strings.Split(hosts, ",")
 	var user, pass string
 	if login != "" {
 		toks := strings.SplitN(login, ":", 2)
 		user = toks[0]
-		pass = toks[1]
+		if len(toks) == 2 {
+			pass = toks[1]
+		}
 	}
 	return &Login{
-		Hosts: hostlist,
-		User:  user,
-		Pass:  pass,
+		Hosts:   hostlist,
+		User:    user,
+		Pass:    pass,
+		Secrets: secrets,
 	}
 }
 
 type Tool struct {
 	Selections map[string]Selector
+
+	// Aliases map names to argument templates, expanded before a
+	// command is selected.
+	Aliases map[string]string
+
+	// Plugin returns the selector running the plugin at path, for
+	// commands which are not in Selections. Nil disables plugins.
+	Plugin func(path string) Selector
 }
 
-func (t *Tool) Start(args []string) {
+// Local reports whether args can be handled without a Marathon host,
+// because they select a local Category or a plugin, or only ask for
+// help.
+func (t *Tool) Local(args []string) bool {
+	args, e := t.expand(args)
+	if e != nil {
+		return true
+	}
 	if len(args) == 0 {
-		check.Usage()
+		return false
+	}
+	selection, ok := t.Selections[args[0]]
+	if !ok {
+		return true
+	}
+	category, ok := selection.(*Category)
+	if !ok {
+		return false
 	}
-	if selection, ok := t.Selections[args[0]]; !ok {
-		check.Usage()
-	} else {
-		selection.Select(args[1:])
+	if helpAction(args[1:]) || wantsHelp(args[2:]) {
+		return true
 	}
+	_, known := category.Actions[args[1]]
+	return category.Local || !known
+}
+
+// Start runs the command selected by args, or prints the help asked
+// for with --help. Aliases are expanded first, and unknown commands
+// run the plugin of that name.
+func (t *Tool) Start(ctx context.Context, args []string) {
+	if len(args) == 0 {
+		t.Usage()
+	}
+	args, e := t.expand(args)
+	check.Check(e == nil, e)
+	selection, ok := t.Selections[args[0]]
+	if !ok && t.Plugin != nil {
+		if path, found := findPlugin(args[0]); found {
+			selection, ok = t.Plugin(path), true
+		}
+	}
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		t.Usage()
+	}
+	if category, ok := selection.(*Category); ok {
+		if helpAction(args[1:]) {
+			fmt.Print(category.Help(args[0]))
+			return
+		}
+		action, ok := category.Actions[args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown action %q\n\n", args[1])
+			fmt.Fprint(os.Stderr, category.Help(args[0]))
+			os.Exit(ExitError)
+		}
+		if wantsHelp(args[2:]) {
+			fmt.Print(actionHelp(args[0], args[1], action))
+			return
+		}
+	}
+	selection.Select(ctx, args[1:])
 }
 
 type Client struct {
-	client http.Client
-	login  *Login
+	client  http.Client
+	login   *Login
+	options Options
+	auth    Authenticator
+
+	lock   sync.Mutex
+	leader string // cached leader host, "" if not one of the hosts
+	known  bool   // whether leader was discovered
 }
 
-func NewClient(login *Login) *Client {
-	return &Client{
-		client: http.Client{},
-		login:  login,
+// NewClient creates a Client for the Marathon hosts of login.
+func NewClient(login *Login, options Options) (*Client, error) {
+	client, e := options.httpClient()
+	if e != nil {
+		return nil, fmt.Errorf("tls configuration error: %v", e)
 	}
+	c := &Client{
+		client:  client,
+		login:   login,
+		options: options,
+	}
+	c.auth = options.Auth.authenticator(login, &c.client)
+	return c, nil
 }
 
+// Do sends the request to Marathon. Requests that mutate state
+// are sent directly to the leader, everything else is tried on
+// each host until one answers. Failed rounds are retried with
+// backoff as configured by Options.
 func (c *Client) Do(r *http.Request) (*http.Response, error) {
+	tries := c.options.Retries + 1
+	var response *http.Response
+	var e error
+	for attempt := 1; attempt <= tries; attempt++ {
+		if e := r.Context().Err(); e != nil {
+			return nil, interrupted(r, e)
+		}
+		if attempt > 1 {
+			if e := sleep(r.Context(), c.options.backoff(attempt-1)); e != nil {
+				return nil, interrupted(r, e)
+			}
+			if !rewind(r) {
+				break
+			}
+		}
+		var retry bool
+		if !c.login.RoundRobin && mutates(r) {
+			response, retry, e = c.doLeader(r, attempt, tries)
+		} else {
+			response, retry, e = c.doAny(r, attempt, tries)
+		}
+		if e != nil && r.Context().Err() != nil {
+			return nil, interrupted(r, r.Context().Err())
+		}
+		if !retry || attempt == tries {
+			break
+		}
+		if response != nil {
+			response.Body.Close()
+			response = nil
+		}
+	}
+	if response == nil && e == nil {
+		e = errors.New("request body cannot be resent")
+	}
+	return response, e
+}
+
+// doAny tries each host until success or run out. The last
+// response is returned if every host answered with a retryable
+// status.
+func (c *Client) doAny(r *http.Request, attempt, tries int) (*http.Response, bool, error) {
+	var last *http.Response
+	var failure error
+	retry := false
+	for i, host := range c.login.Hosts {
+		if i > 0 && !rewind(r) {
+			break
+		}
+		response, e := c.doHost(r, host)
+		if e == nil && !retryableStatus(response.StatusCode) {
+			if last != nil {
+				last.Body.Close()
+			}
+			return response, false, nil
+		}
+		if errors.Is(e, ErrAuth) || r.Context().Err() != nil {
+			// every host would fail the same way
+			return nil, false, e
+		}
+		logAttempt(r, host, attempt, tries, response, e)
+		if e != nil {
+			failure = e
+			retry = retry || retryableError(e)
+			continue
+		}
+		retry = true
+		if last != nil {
+			last.Body.Close()
+		}
+		last = response
+	}
+	if last != nil {
+		return last, retry, nil
+	}
+	if failure != nil {
+		return nil, retry, fmt.Errorf("%w: requests to all hosts failed: %s", ErrUnreachable, describe(failure))
+	}
+	return nil, retry, fmt.Errorf("%w: requests to all hosts failed", ErrUnreachable)
+}
+
+// interrupted explains that r was abandoned because its context
+// is done, e is one of context.Canceled or context.DeadlineExceeded.
+func interrupted(r *http.Request, e error) error {
+	return fmt.Errorf("%s %s: %w", r.Method, trimHost(r), e)
+}
+
+// doLeader sends the request to the cached leader, forgetting
+// the leader if it has gone away or stepped down so that the
+// next attempt rediscovers it. The request goes to any host,
+// which proxies it to the leader, if the leader cannot be found,
+// is not one of the configured hosts or fails the request.
+func (c *Client) doLeader(r *http.Request, attempt, tries int) (*http.Response, bool, error) {
+	leader, e := c.findLeader(r.Context())
+	if errors.Is(e, ErrAuth) || r.Context().Err() != nil {
+		return nil, false, e
+	}
+	if e != nil || leader == "" {
+		return c.doAny(r, attempt, tries)
+	}
+	response, e := c.doHost(r, leader)
+	if e == nil && !retryableStatus(response.StatusCode) {
+		return response, false, nil
+	}
+	if errors.Is(e, ErrAuth) {
+		return nil, false, e
+	}
+	logAttempt(r, leader, attempt, tries, response, e)
+	// the leader may have moved, look it up again next time
+	c.forget(leader)
+	if r.Context().Err() != nil || !rewind(r) {
+		if e != nil {
+			return nil, retryableError(e), fmt.Errorf("%w: request to leader failed: %s", ErrUnreachable, describe(e))
+		}
+		return response, true, nil
+	}
+	if response != nil {
+		response.Body.Close()
+	}
+	return c.doAny(r, attempt, tries)
+}
+
+// doHost sends r to host, leaving r as it was found. If the
+// credentials are rejected and can be renewed, r is sent again.
+func (c *Client) doHost(r *http.Request, host string) (*http.Response, error) {
+	original := r.URL
 	// this is not ghetto at all
-	original := r.URL.String()
-	// try each host until success or run out
+	fixed := strings.Replace(original.String(), "HOST", host, 1)
+	url, e := url.Parse(fixed)
+	if e != nil {
+		return nil, fmt.Errorf("could not parse url %s: %v", fixed, e)
+	}
+	r.URL = url
+	defer func() { r.URL = original }()
+	if e := c.auth.Apply(r); e != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuth, e)
+	}
+	response, e := c.send(r)
+	if e != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, e
+	}
+	if !c.auth.Invalidate() || !rewind(r) {
+		return response, nil
+	}
+	response.Body.Close()
+	if e := c.auth.Apply(r); e != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuth, e)
+	}
+	return c.send(r)
+}
+
+// send does the actual round trip of r, tracing it if asked to.
+func (c *Client) send(r *http.Request) (*http.Response, error) {
+	if !c.options.Trace {
+		return c.client.Do(r)
+	}
+	start := time.Now()
+	response, e := c.client.Do(r)
+	trace(r, response, e, time.Now().Sub(start), c.options)
+	return response, e
+}
+
+// findLeader returns the configured host which is the current
+// Marathon leader, or "" if the leader is not one of them, asking
+// Marathon via /v2/leader if it is not already known.
+func (c *Client) findLeader(ctx context.Context) (string, error) {
+	c.lock.Lock()
+	leader, known := c.leader, c.known
+	c.lock.Unlock()
+	if known {
+		return leader, nil
+	}
 	for _, host := range c.login.Hosts {
-		fixed := strings.Replace(original, "HOST", host, 1)
-		url, e := url.Parse(fixed)
-		check.Check(e == nil, "could not parse fixed url", e)
-		r.URL = url
-		if response, e := c.client.Do(r); e == nil {
-			return response, nil
-		} else {
-			fmt.Fprintf(os.Stderr, "request to %s failed\n", host)
-			ourl, e := url.Parse(original)
-			check.Check(e == nil, "could not parse original url")
-			r.URL = ourl
+		request, e := c.request(ctx, "GET", "/v2/leader", nil)
+		if e != nil {
+			return "", e
 		}
+		response, e := c.doHost(request, host)
+		if errors.Is(e, ErrAuth) {
+			return "", e
+		}
+		if e != nil {
+			continue
+		}
+		body, e := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		code := response.StatusCode
+		if code == http.StatusUnauthorized || code == http.StatusForbidden {
+			return "", newAPIError("GET", "/v2/leader", code, body)
+		}
+		var which Which
+		if code != 200 || e != nil || json.Unmarshal(body, &which) != nil || which.Leader == "" {
+			continue
+		}
+		leader = c.leaderHost(which.Leader)
+		c.lock.Lock()
+		c.leader, c.known = leader, true
+		c.lock.Unlock()
+		return leader, nil
 	}
-	return nil, errors.New("requests to all hosts failed")
+	return "", fmt.Errorf("%w: unable to discover leader", ErrUnreachable)
 }
 
-func (c *Client) GET(path string) *http.Request {
-	url := "HOST" + path
-	request, e := http.NewRequest("GET", url, nil)
-	check.Check(e == nil, "failed to crete GET request", e)
-	c.tweak(request)
-	return request
+// leaderHost returns the configured host for the host:port reported
+// by /v2/leader, or "" if there is none.
+func (c *Client) leaderHost(leader string) string {
+	for _, host := range c.login.Hosts {
+		if u, e := url.Parse(host); e == nil && u.Host == leader {
+			return host
+		}
+	}
+	return ""
 }
 
-func (c *Client) POST(path string, body io.Reader) *http.Request {
-	url := "HOST" + path
-	request, e := http.NewRequest("POST", url, body)
-	check.Check(e == nil, "failed to create POST request", e)
-	c.tweak(request)
-	return request
+func (c *Client) forget(leader string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.leader == leader {
+		c.leader, c.known = "", false
+	}
 }
 
-func (c *Client) DELETE(path string) *http.Request {
-	url := "HOST" + path
-	request, e := http.NewRequest("DELETE", url, nil)
-	check.Check(e == nil, "failed to create DELETE request", e)
-	c.tweak(request)
-	return request
+func mutates(r *http.Request) bool {
+	switch r.Method {
+	case "POST", "PUT", "DELETE":
+		return true
+	}
+	return false
 }
 
-func (c *Client) PUT(path string, body io.ReadCloser) *http.Request {
-	url := "HOST" + path
-	request, e := http.NewRequest("PUT", url, body)
-	check.Check(e == nil, "failed to create PUT request", e)
+// rewind resets the body of r so that it may be sent again.
+func rewind(r *http.Request) bool {
+	if r.Body == nil {
+		return true
+	}
+	if r.GetBody == nil {
+		return false
+	}
+	body, e := r.GetBody()
+	if e != nil {
+		return false
+	}
+	r.Body = body
+	return true
+}
+
+// request creates a request for path, which is sent to whichever
+// host Do chooses.
+func (c *Client) request(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	body, e := replayable(body)
+	if e != nil {
+		return nil, e
+	}
+	request, e := http.NewRequestWithContext(ctx, method, "HOST"+path, body)
+	if e != nil {
+		return nil, fmt.Errorf("failed to create %s request: %v", method, e)
+	}
 	c.tweak(request)
-	return request
+	return request, nil
+}
+
+// replayable reads all of body into memory so that the request
+// can be sent again to another host on failover; http.NewRequest
+// sets GetBody and ContentLength for a *bytes.Reader.
+func replayable(body io.Reader) (io.Reader, error) {
+	if body == nil {
+		return nil, nil
+	}
+	b, e := ioutil.ReadAll(body)
+	if e != nil {
+		return nil, fmt.Errorf("failed to read request body: %v", e)
+	}
+	return bytes.NewReader(b), nil
 }
 
 // tweak will set:
 // Content-Type: application/json
 // Accept: application/json
-// Accept-Encoding: gzip, deflate, compress
+// Credentials are added for each host the request is sent to.
 func (c *Client) tweak(request *http.Request) {
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Accept", "application/json")
-	if c.login.NeedsAuth() {
-		request.SetBasicAuth(c.login.User, c.login.Pass)
-	}
 }
@@ -0,0 +1,168 @@
+package marathon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// host is a fake Marathon recording the bodies of the requests that
+// change state, failing them as fail says after reading the body.
+type host struct {
+	*httptest.Server
+
+	lock   sync.Mutex
+	bodies [][]byte
+	leader string // answered by /v2/leader, none if empty
+	fail   string // "", "503" or "drop"
+}
+
+func newHost(t *testing.T, fail string) *host {
+	h := &host{fail: fail}
+	h.Server = httptest.NewServer(http.HandlerFunc(h.serve))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *host) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v2/leader" {
+		if h.leader == "" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"leader":%q}`, h.leader)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	h.lock.Lock()
+	h.bodies = append(h.bodies, body)
+	h.lock.Unlock()
+	switch h.fail {
+	case "503":
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case "drop":
+		conn, _, e := w.(http.Hijacker).Hijack()
+		if e == nil {
+			conn.Close()
+		}
+	default:
+		w.Write([]byte(`{"deploymentId":"1","version":"1"}`))
+	}
+}
+
+func (h *host) received() [][]byte {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.bodies
+}
+
+// address is the host:port /v2/leader reports for h.
+func (h *host) address() string {
+	return strings.TrimPrefix(h.URL, "http://")
+}
+
+func testClient(t *testing.T, roundRobin bool, hosts ...*host) *Client {
+	var urls []string
+	for _, h := range hosts {
+		urls = append(urls, h.URL)
+	}
+	login := NewLogin(strings.Join(urls, ","), "")
+	login.RoundRobin = roundRobin
+	c, e := NewClient(login, Options{ConnectTimeout: time.Second, RequestTimeout: 10 * time.Second})
+	if e != nil {
+		t.Fatal(e)
+	}
+	return c
+}
+
+// testBody is large enough to need several reads, and not valid UTF-8.
+func testBody() []byte {
+	b := make([]byte, 100000)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+func send(t *testing.T, c *Client, method string, body []byte) {
+	r, e := c.request(context.Background(), method, "/v2/apps/x", bytes.NewReader(body))
+	if e != nil {
+		t.Fatal(e)
+	}
+	response, e := c.Do(r)
+	if e != nil {
+		t.Fatalf("%s failed: %v", method, e)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("%s answered %d", method, response.StatusCode)
+	}
+}
+
+func checkBodies(t *testing.T, h *host, want []byte, n int) {
+	got := h.received()
+	if len(got) != n {
+		t.Fatalf("got %d requests, want %d", len(got), n)
+	}
+	for i, body := range got {
+		if !bytes.Equal(body, want) {
+			t.Errorf("request %d: got %d bytes, want the %d bytes sent", i, len(body), len(want))
+		}
+	}
+}
+
+func TestFailoverResendsBody(t *testing.T) {
+	for _, fail := range []string{"503", "drop"} {
+		for _, method := range []string{"POST", "PUT"} {
+			t.Run(fail+"/"+method, func(t *testing.T) {
+				first, second := newHost(t, fail), newHost(t, "")
+				body := testBody()
+				send(t, testClient(t, true, first, second), method, body)
+				checkBodies(t, first, body, 1)
+				checkBodies(t, second, body, 1)
+			})
+		}
+	}
+}
+
+func TestLeaderFailoverResendsBody(t *testing.T) {
+	for _, fail := range []string{"503", "drop"} {
+		for _, method := range []string{"POST", "PUT"} {
+			t.Run(fail+"/"+method, func(t *testing.T) {
+				first, second := newHost(t, fail), newHost(t, "")
+				first.leader = first.address()
+				second.leader = first.address()
+				body := testBody()
+				send(t, testClient(t, false, first, second), method, body)
+				checkBodies(t, second, body, 1)
+				if len(first.received()) == 0 {
+					t.Error("the leader was not tried")
+				}
+			})
+		}
+	}
+}
+
+func TestUnknownLeaderUsesHosts(t *testing.T) {
+	only := newHost(t, "")
+	only.leader = "10.255.255.1:8080"
+	body := testBody()
+	send(t, testClient(t, false, only), "PUT", body)
+	checkBodies(t, only, body, 1)
+}
+
+func TestLeaderReceivesChanges(t *testing.T) {
+	first, second := newHost(t, ""), newHost(t, "")
+	first.leader = second.address()
+	body := testBody()
+	send(t, testClient(t, false, first, second), "POST", body)
+	checkBodies(t, first, body, 0)
+	checkBodies(t, second, body, 1)
+}
@@ -0,0 +1,385 @@
+package marathon
+
+// Shell completion: the scripts printed by command completion, and
+// the candidates they ask marathonctl for when completing arguments
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// CompleteEnv is set by the completion scripts when they run
+// marathonctl to list the candidates for the next argument.
+const CompleteEnv = "MARATHONCTL_COMPLETE"
+
+// completionTTL is how long ids fetched from Marathon are reused.
+const completionTTL = 30 * time.Second
+
+// completer is implemented by actions which can suggest their next
+// argument, given the arguments before it with any flags removed.
+// Returning nothing lets the shell complete file names.
+type completer interface {
+	Complete(ctx context.Context, args []string) []string
+}
+
+// Complete returns the candidates for the last of args, the word
+// being completed. Words starting with - complete to the flags of
+// the action.
+func (t *Tool) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	words, current := args[:len(args)-1], args[len(args)-1]
+	if len(words) == 0 {
+		return append(t.names(), t.aliasNames()...)
+	}
+	words, e := t.expand(words)
+	if e != nil || len(words) == 0 {
+		return nil
+	}
+	category, ok := t.Selections[words[0]].(*Category)
+	if !ok {
+		return nil
+	}
+	if len(words) == 1 {
+		return category.names()
+	}
+	action := category.Actions[words[1]]
+	flags, hasFlags := action.(flagged)
+	if strings.HasPrefix(current, "-") {
+		if hasFlags {
+			return flagNames(flags.Flags())
+		}
+		return nil
+	}
+	words = words[2:]
+	if hasFlags {
+		var e error
+		if words, e = parseFlags(flags.Flags(), words); e != nil {
+			return nil
+		}
+	}
+	c, ok := action.(completer)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	return c.Complete(ctx, words)
+}
+
+func (t *Tool) names() []string {
+	var names []string
+	for name := range t.Selections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Category) names() []string {
+	var names []string
+	for name := range c.Actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completions returns the ids listed by list, cached on disk for a
+// little while so that pressing tab repeatedly does not ask Marathon
+// every time.
+func (c *Client) completions(ctx context.Context, kind string, list func(context.Context) ([]string, error)) []string {
+	if c == nil || len(c.login.Hosts) == 0 || c.login.Hosts[0] == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(strings.Join(c.login.Hosts, ",") + " " + kind))
+	cache := filepath.Join(os.Getenv("HOME"), ".cache", "marathonctl", "completion", hex.EncodeToString(sum[:8]))
+	if info, e := os.Stat(cache); e == nil && time.Since(info.ModTime()) < completionTTL {
+		if b, e := ioutil.ReadFile(cache); e == nil {
+			return strings.Fields(string(b))
+		}
+	}
+	ids, e := list(ctx)
+	if e != nil {
+		return nil
+	}
+	sort.Strings(ids)
+	if os.MkdirAll(filepath.Dir(cache), 0700) == nil {
+		ioutil.WriteFile(cache, []byte(strings.Join(ids, "\n")), 0600)
+	}
+	return ids
+}
+
+func (c *Client) appIDs(ctx context.Context) []string {
+	return c.completions(ctx, "apps", func(ctx context.Context) ([]string, error) {
+		apps, e := c.ListApps(ctx)
+		if e != nil {
+			return nil, e
+		}
+		var ids []string
+		for _, app := range apps.Apps {
+			ids = append(ids, app.ID)
+		}
+		return ids, nil
+	})
+}
+
+func (c *Client) groupIDs(ctx context.Context) []string {
+	return c.completions(ctx, "groups", func(ctx context.Context) ([]string, error) {
+		root, e := c.GetGroup(ctx, "")
+		if e != nil {
+			return nil, e
+		}
+		var ids []string
+		var gather func(g *Group)
+		gather = func(g *Group) {
+			for _, group := range g.Groups {
+				ids = append(ids, group.GroupID)
+				gather(group)
+			}
+		}
+		gather(root)
+		return ids, nil
+	})
+}
+
+func (c *Client) deploymentIDs(ctx context.Context) []string {
+	return c.completions(ctx, "deployments", func(ctx context.Context) ([]string, error) {
+		deploys, e := c.ListDeployments(ctx)
+		if e != nil {
+			return nil, e
+		}
+		var ids []string
+		for _, deploy := range deploys {
+			ids = append(ids, deploy.DeployID)
+		}
+		return ids, nil
+	})
+}
+
+func (c *Client) taskIDs(ctx context.Context, app string) []string {
+	return c.completions(ctx, "tasks "+app, func(ctx context.Context) ([]string, error) {
+		tasks, e := c.ListAppTasks(ctx, app)
+		if e != nil {
+			return nil, e
+		}
+		var ids []string
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids, nil
+	})
+}
+
+// Completion prints the completion script of Shell for the commands
+// of Tool.
+type Completion struct {
+	Tool  *Tool
+	Shell string
+}
+
+func (c Completion) Apply(ctx context.Context, args []string) {
+	check.Check(len(args) == 0, "no arguments")
+	var script string
+	switch c.Shell {
+	case "bash":
+		script = c.bash()
+	case "zsh":
+		script = c.zsh()
+	case "fish":
+		script = c.fish()
+	default:
+		check.Check(false, "unsupported shell", c.Shell)
+	}
+	fmt.Print(script)
+}
+
+func (c Completion) Usage() []Usage {
+	return []Usage{
+		{"", "print the " + c.Shell + " completion script"},
+	}
+}
+
+// flags returns the global flags, those taking a value and the
+// boolean ones.
+func flags() (valued, booleans []string) {
+	flag.VisitAll(func(f *flag.Flag) {
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			booleans = append(booleans, "-"+f.Name)
+		} else {
+			valued = append(valued, "-"+f.Name)
+		}
+	})
+	return
+}
+
+// commands returns the names completing the first argument, the
+// commands and the aliases of the config files when the script was
+// printed.
+func (c Completion) commands() []string {
+	return append(c.Tool.names(), c.Tool.aliasNames()...)
+}
+
+func (c Completion) bash() string {
+	valued, booleans := flags()
+	var b bytes.Buffer
+	b.WriteString(`# bash completion for marathonctl, load with
+#   source <(marathonctl completion bash)
+_marathonctl() {
+    local cur=${COMP_WORDS[COMP_CWORD]} args=() i
+    local IFS=$'\n'
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case ${COMP_WORDS[i]} in
+`)
+	fmt.Fprintf(&b, "        %s) ((i++)) ;;\n", strings.Join(valued, "|"))
+	b.WriteString(`        -*) ;;
+        *) args+=("${COMP_WORDS[i]}") ;;
+        esac
+    done
+    if [[ $cur == -* && ${#args[@]} == 0 ]]; then
+`)
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(append(valued, booleans...), "\n"))
+	b.WriteString(`        return
+    fi
+    case ${#args[@]} in
+`)
+	fmt.Fprintf(&b, "    0) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", strings.Join(c.commands(), "\n"))
+	b.WriteString("    1) case ${args[0]} in\n")
+	for _, name := range c.Tool.names() {
+		if category, ok := c.Tool.Selections[name].(*Category); ok {
+			fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", name, strings.Join(category.names(), "\n"))
+		}
+	}
+	if aliases := c.Tool.aliasNames(); len(aliases) > 0 {
+		fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W \"$(%s=bash \"${COMP_WORDS[@]:0:COMP_CWORD+1}\" 2>/dev/null)\" -- \"$cur\")) ;;\n", strings.Join(aliases, "|"), CompleteEnv)
+	}
+	fmt.Fprintf(&b, `        esac ;;
+    *) COMPREPLY=($(compgen -W "$(%s=bash "${COMP_WORDS[@]:0:COMP_CWORD+1}" 2>/dev/null)" -- "$cur")) ;;
+    esac
+}
+complete -o default -F _marathonctl marathonctl
+`, CompleteEnv)
+	return b.String()
+}
+
+func (c Completion) zsh() string {
+	valued, booleans := flags()
+	var b bytes.Buffer
+	b.WriteString(`#compdef marathonctl
+# zsh completion for marathonctl, load with
+#   source <(marathonctl completion zsh)
+# or save as _marathonctl in a directory of $fpath
+_marathonctl() {
+  local -a args candidates
+  local i
+  for ((i = 2; i < CURRENT; i++)); do
+    case ${words[i]} in
+`)
+	fmt.Fprintf(&b, "      %s) ((i++)) ;;\n", strings.Join(valued, "|"))
+	b.WriteString(`      -*) ;;
+      *) args+=("${words[i]}") ;;
+    esac
+  done
+  if [[ ${words[CURRENT]} == -* && ${#args} == 0 ]]; then
+`)
+	fmt.Fprintf(&b, "    candidates=(%s)\n", strings.Join(append(valued, booleans...), " "))
+	b.WriteString("  else\n    case ${#args} in\n")
+	fmt.Fprintf(&b, "      0) candidates=(%s) ;;\n", strings.Join(c.commands(), " "))
+	b.WriteString("      1) case ${args[1]} in\n")
+	for _, name := range c.Tool.names() {
+		if category, ok := c.Tool.Selections[name].(*Category); ok {
+			fmt.Fprintf(&b, "          %s) candidates=(%s) ;;\n", name, strings.Join(category.names(), " "))
+		}
+	}
+	if aliases := c.Tool.aliasNames(); len(aliases) > 0 {
+		fmt.Fprintf(&b, "          %s) candidates=(${(f)\"$(%s=zsh \"${(@)words[1,CURRENT]}\" 2>/dev/null)\"}) ;;\n", strings.Join(aliases, "|"), CompleteEnv)
+	}
+	fmt.Fprintf(&b, `        esac ;;
+      *) candidates=(${(f)"$(%s=zsh "${(@)words[1,CURRENT]}" 2>/dev/null)"}) ;;
+    esac
+  fi
+  compadd -a candidates || _files
+}
+if [[ $funcstack[1] == _marathonctl ]]; then
+  _marathonctl "$@"
+else
+  compdef _marathonctl marathonctl
+fi
+`, CompleteEnv)
+	return b.String()
+}
+
+func (c Completion) fish() string {
+	valued, booleans := flags()
+	var b bytes.Buffer
+	b.WriteString(`# fish completion for marathonctl, load with
+#   marathonctl completion fish | source
+function __marathonctl_args
+    set -l args
+    set -l skip 0
+    for word in (commandline -opc)[2..-1]
+        if test $skip = 1
+            set skip 0
+            continue
+        end
+        switch $word
+`)
+	fmt.Fprintf(&b, "            case %s\n                set skip 1\n", strings.Join(valued, " "))
+	b.WriteString(`            case '-*'
+            case '*'
+                set args $args $word
+        end
+    end
+    printf '%s\n' $args
+end
+
+function __marathonctl_complete
+    set -l args (__marathonctl_args)
+    switch (count $args)
+        case 0
+`)
+	fmt.Fprintf(&b, "            printf '%%s\\n' %s\n", strings.Join(c.commands(), " "))
+	b.WriteString("        case 1\n            switch $args[1]\n")
+	for _, name := range c.Tool.names() {
+		if category, ok := c.Tool.Selections[name].(*Category); ok {
+			fmt.Fprintf(&b, "                case %s\n                    printf '%%s\\n' %s\n", name, strings.Join(category.names(), " "))
+		}
+	}
+	if aliases := c.Tool.aliasNames(); len(aliases) > 0 {
+		fmt.Fprintf(&b, "                case %s\n                    set -l cur (commandline -ct)\n                    env %s=fish (commandline -opc) \"$cur\" 2>/dev/null\n", strings.Join(aliases, " "), CompleteEnv)
+	}
+	fmt.Fprintf(&b, `            end
+        case '*'
+            set -l cur (commandline -ct)
+            set -l candidates (env %s=fish (commandline -opc) "$cur" 2>/dev/null)
+            if test (count $candidates) -gt 0
+                printf '%%s\n' $candidates
+            else
+                __fish_complete_path $cur
+            end
+    end
+end
+
+complete -c marathonctl -f -a '(__marathonctl_complete)'
+`, CompleteEnv)
+	for _, f := range valued {
+		fmt.Fprintf(&b, "complete -c marathonctl -o %s -r\n", strings.TrimPrefix(f, "-"))
+	}
+	for _, f := range booleans {
+		fmt.Fprintf(&b, "complete -c marathonctl -o %s\n", strings.TrimPrefix(f, "-"))
+	}
+	return b.String()
+}
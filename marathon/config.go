@@ -5,85 +5,493 @@ package marathon
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/shoenig/config"
 )
 
-// cli arguments override configuration file
-func cliargs() (config, host, login, format string) {
-	flag.StringVar(&config, "c", "", "config file")
-	flag.StringVar(&host, "h", "", "marathon host with transport and port")
-	flag.StringVar(&login, "u", "", "username and password")
-	flag.StringVar(&format, "f", "", "output format")
+// Settings is the resolved configuration of marathonctl.
+type Settings struct {
+	File       string // config file edited by commands, if any
+	Context    string // active context, if any
+	Host       string
+	Login      string
+	Secrets    []Secret // supply the password if Login has none
+	Format     string
+	RoundRobin bool
+	Options    Options
+	Timeout    time.Duration     // limit for the whole command, 0 is no limit
+	Help       bool              // -help was given
+	Aliases    map[string]string // alias names to argument templates
+	Table      TableOptions      // -columns and -no-headers
+
+	above []layer // flags and environment
+	files []layer // config files, highest precedence first
+}
+
+// layers returns where values come from, highest precedence first,
+// with the keys of context applied to the config files.
+func (s Settings) layers(context string) []layer {
+	layers := append([]layer{}, s.above...)
+	for _, file := range s.files {
+		layers = append(layers, layer{file.name, inContext(file.get, context)})
+	}
+	return append(layers, defaultLayer())
+}
+
+// setting is one key of the configuration, along with the
+// environment variable and flag that may also set it.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	secret bool
+}
+
+var settings = []setting{
+	{"marathon.host", "MARATHON_HOST", "h", false},
+	{"marathon.user", "MARATHON_USER", "u", false},
+	{"marathon.password", "MARATHON_PASSWORD", "u", true},
+	{"marathon.password_file", "MARATHON_PASSWORD_FILE", "password-file", false},
+	{"marathon.password_command", "MARATHON_PASSWORD_COMMAND", "password-command", false},
+	{"marathon.format", "MARATHON_FORMAT", "f", false},
+	{"marathon.context", "MARATHON_CONTEXT", "context", false},
+	{"marathon.contexts", "", "", false},
+	{"marathon.roundrobin", "MARATHON_ROUNDROBIN", "roundrobin", false},
+	{"marathon.timeout.connect", "MARATHON_CONNECT_TIMEOUT", "connect-timeout", false},
+	{"marathon.timeout.request", "MARATHON_REQUEST_TIMEOUT", "request-timeout", false},
+	{"marathon.retries", "MARATHON_RETRIES", "retries", false},
+	{"marathon.backoff", "MARATHON_BACKOFF", "backoff", false},
+	{"marathon.tls.ca", "MARATHON_TLS_CA", "tls-ca", false},
+	{"marathon.tls.cert", "MARATHON_TLS_CERT", "tls-cert", false},
+	{"marathon.tls.key", "MARATHON_TLS_KEY", "tls-key", false},
+	{"marathon.tls.insecure", "MARATHON_TLS_INSECURE", "tls-insecure", false},
+	{"marathon.token", "MARATHON_TOKEN", "token", true},
+	{"marathon.token_file", "MARATHON_TOKEN_FILE", "token-file", false},
+	{"marathon.token_command", "MARATHON_TOKEN_COMMAND", "token-command", false},
+	{"marathon.login_url", "MARATHON_LOGIN_URL", "login-url", false},
+}
+
+// known reports whether key is a setting, either at the top level
+// or of a context.
+func known(key string) bool {
+	if strings.HasPrefix(key, aliasPrefix) {
+		return len(key) > len(aliasPrefix)
+	}
+	if strings.HasPrefix(key, "context.") {
+		parts := strings.SplitN(key, ".", 3)
+		if len(parts) < 3 || parts[2] == "context" || parts[2] == "contexts" {
+			return false
+		}
+		key = "marathon." + parts[2]
+	}
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// defaults are the values used when nothing else sets a key.
+var defaults = map[string]string{
+	"marathon.format":          "human",
+	"marathon.timeout.connect": DefaultOptions.ConnectTimeout.String(),
+	"marathon.timeout.request": DefaultOptions.RequestTimeout.String(),
+	"marathon.retries":         strconv.Itoa(DefaultOptions.Retries),
+	"marathon.backoff":         DefaultOptions.Backoff.String(),
+}
+
+// cli arguments override everything else, only the flags actually
+// given are returned in set
+func cliargs() (config string, set map[string]string, s Settings) {
+	flag.StringVar(&config, "c", "", "config `file` (settings are taken from flags, then MARATHON_* environment variables, then this file, ~/.config/marathonctl/config and /etc/marathonctl)")
+	flag.String("context", "", "use context `name` of the config file")
+	flag.String("h", "", "comma separated marathon `hosts` with transport and port")
+	flag.String("u", "", "`user:password`, or just user with one of -password-file, -password-command or auth set")
+	flag.String("password-file", "", "read the password from `file`")
+	flag.String("password-command", "", "run `command` to print the password")
+	flag.String("f", "", "output `format` (default human)")
+	flag.String("o", "", "output `format`, same as -f")
+	columns := flag.String("columns", "", "show only the comma separated `columns` of tables, by name")
+	flag.BoolVar(&s.Table.NoHeaders, "no-headers", false, "leave the column names out of tables")
+	flag.Bool("roundrobin", false, "send every request to the first host that answers, instead of sending changes to the leader")
+	flag.Duration("connect-timeout", 0, "connect timeout (default 5s)")
+	flag.Duration("request-timeout", 0, "request timeout (default 60s, 0 for no limit)")
+	flag.Int("retries", 0, "retry failed requests `N` times (default 2)")
+	flag.Duration("backoff", 0, "wait before first retry, doubled each retry (default 500ms)")
+	flag.String("tls-ca", "", "PEM CA certificates `file` to trust for https hosts")
+	flag.String("tls-cert", "", "PEM client certificate `file` for mutual TLS")
+	flag.String("tls-key", "", "PEM client key `file` for mutual TLS")
+	flag.Bool("tls-insecure", false, "do not verify the server certificate")
+	flag.String("token", "", "send \"Authorization: token=[token]\" with `token`")
+	flag.String("token-file", "", "read the token from `file`")
+	flag.String("token-command", "", "run `command` to print the token")
+	flag.String("login-url", "", "DC/OS ACS login endpoint `url`, see auth login")
+	flag.BoolVar(&s.Options.Trace, "trace", false, "log every request and response status on stderr, with the Authorization header redacted")
+	flag.BoolVar(&s.Options.Trace, "v", false, "same as -trace")
+	flag.BoolVar(&s.Options.TraceCurl, "curl", false, "like -trace, and also log each request as a curl command")
+	flag.DurationVar(&s.Timeout, "timeout", 0, "give up on the whole command after `duration`")
+	flag.BoolVar(&s.Help, "help", false, "show this help")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "run marathonctl -help for usage")
+	}
 	flag.Parse()
+	s.Options.Trace = s.Options.Trace || s.Options.TraceCurl
+
+	if *columns != "" {
+		s.Table.Columns = strings.Split(*columns, ",")
+	}
+
+	set = make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+	if set["f"] == "" {
+		set["f"] = set["o"]
+	}
 	return
 }
 
-func readConfigfile(filename string) (host, login, format string, e error) {
+// lookup returns the value of a key such as "marathon.host", or ""
+// if it is not set.
+type lookup func(key string) string
+
+// layer is one place configuration comes from.
+type layer struct {
+	name string
+	get  lookup
+}
+
+// first returns a lookup giving the first value set by any of layers.
+func first(layers []layer) lookup {
+	return func(key string) string {
+		v, _ := resolve(layers, key)
+		return v
+	}
+}
+
+// passwordKeys supply the password of marathon.user, all of them
+// come from the same layer.
+var passwordKeys = []string{"marathon.password", "marathon.password_file", "marathon.password_command"}
+
+// resolve returns the value of key and the name of the layer it came
+// from, or "" and "" if no layer sets key.
+func resolve(layers []layer, key string) (string, string) {
+	if contains(passwordKeys, key) {
+		return resolvePassword(layers, key)
+	}
+	for _, l := range layers {
+		if v := l.get(key); v != "" {
+			return v, l.name
+		}
+	}
+	return "", ""
+}
+
+// resolvePassword returns key from the first layer setting any of
+// passwordKeys that does not set a user other than marathon.user, so
+// that the password of one user is never sent with another.
+func resolvePassword(layers []layer, key string) (string, string) {
+	user, _ := resolve(layers, "marathon.user")
+	for _, l := range layers {
+		if u := l.get("marathon.user"); u != "" && u != user {
+			continue
+		}
+		for _, k := range passwordKeys {
+			if l.get(k) != "" {
+				if v := l.get(key); v != "" {
+					return v, l.name
+				}
+				return "", ""
+			}
+		}
+	}
+	return "", ""
+}
+
+func flagLayer(set map[string]string) layer {
+	return layer{"flag", func(key string) string {
+		for _, s := range settings {
+			if s.key != key || s.flag == "" {
+				continue
+			}
+			v := set[s.flag]
+			if s.flag != "u" || v == "" {
+				return v
+			}
+			// -u is user:password
+			toks := strings.SplitN(v, ":", 2)
+			if key == "marathon.user" {
+				return toks[0]
+			}
+			if len(toks) == 2 {
+				return toks[1]
+			}
+			return ""
+		}
+		return ""
+	}}
+}
+
+func envLayer() layer {
+	return layer{"env", func(key string) string {
+		for _, s := range settings {
+			if s.key == key && s.env != "" {
+				return os.Getenv(s.env)
+			}
+		}
+		return ""
+	}}
+}
+
+func defaultLayer() layer {
+	return layer{"default", func(key string) string {
+		return defaults[key]
+	}}
+}
+
+// readConfigfile reads the keys of filename, which is a YAML or
+// JSON file if it is named so, otherwise a properties file.
+func readConfigfile(filename string) (lookup, error) {
+	if structured(filename) {
+		keys, e := readStructured(filename)
+		if e != nil {
+			return nil, fmt.Errorf("%s: %v", filename, e)
+		}
+		return func(key string) string {
+			return keys[key]
+		}, nil
+	}
 	c, e := config.ReadProperties(filename)
 	if e != nil {
-		return "", "", "", e
+		return nil, fmt.Errorf("%s: %v", filename, e)
+	}
+	return func(key string) string {
+		return c.GetStringOr(key, "")
+	}, nil
+}
+
+// inContext returns a lookup preferring the keys of context
+// ("context.[name].host" for "marathon.host").
+func inContext(get lookup, context string) lookup {
+	if context == "" {
+		return get
+	}
+	return func(key string) string {
+		if v := get("context." + context + "." + strings.TrimPrefix(key, "marathon.")); v != "" {
+			return v
+		}
+		return get(key)
+	}
+}
+
+// contextNames returns the contexts listed in marathon.contexts.
+func contextNames(get lookup) []string {
+	var names []string
+	for _, name := range strings.Split(get("marathon.contexts"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
 	}
-	h := c.GetStringOr("marathon.host", "")
-	u := c.GetStringOr("marathon.user", "")
-	p := c.GetStringOr("marathon.password", "")
-	f := c.GetStringOr("marathon.format", "")
+	return false
+}
 
-	l := ""
+func settingsFrom(get lookup) (Settings, error) {
+	h := get("marathon.host")
+	u := get("marathon.user")
+	p := get("marathon.password")
+	f := get("marathon.format")
+	r := get("marathon.roundrobin")
+
+	l := u
 	if u != "" && p != "" {
 		l = u + ":" + p
 	}
+	var secrets []Secret
+	if u != "" && p == "" {
+		if v := get("marathon.password_file"); v != "" {
+			secrets = append(secrets, PasswordFile(v))
+		}
+		if v := get("marathon.password_command"); v != "" {
+			secrets = append(secrets, PasswordCommand(v))
+		}
+		secrets = append(secrets, StoredPassword(u, h))
+	}
+
+	o := DefaultOptions
+	if e := durationProperty(get, "marathon.timeout.connect", &o.ConnectTimeout); e != nil {
+		return Settings{}, e
+	}
+	if e := durationProperty(get, "marathon.timeout.request", &o.RequestTimeout); e != nil {
+		return Settings{}, e
+	}
+	if e := durationProperty(get, "marathon.backoff", &o.Backoff); e != nil {
+		return Settings{}, e
+	}
+	o.TLS = TLS{
+		CA:       get("marathon.tls.ca"),
+		Cert:     get("marathon.tls.cert"),
+		Key:      get("marathon.tls.key"),
+		Insecure: get("marathon.tls.insecure") == "true",
+	}
+	o.Auth = AuthOptions{
+		Token:        get("marathon.token"),
+		TokenFile:    get("marathon.token_file"),
+		TokenCommand: get("marathon.token_command"),
+		LoginURL:     get("marathon.login_url"),
+	}
+	if v := get("marathon.retries"); v != "" {
+		n, e := strconv.Atoi(v)
+		if e != nil || n < 0 {
+			return Settings{}, fmt.Errorf("marathon.retries: invalid value %q", v)
+		}
+		o.Retries = n
+	}
 
-	return h, l, f, nil
+	return Settings{
+		Host:       h,
+		Login:      l,
+		Secrets:    secrets,
+		Format:     f,
+		RoundRobin: r == "true",
+		Options:    o,
+	}, nil
 }
 
-func configFile() string {
-	configLocations := [2]string{os.Getenv("HOME") + "/.config/marathonctl/config", "/etc/marathonctl"}
-	for _, location := range configLocations {
-		if _, err := os.Stat(location); err == nil {
-			return location
+// durationProperty sets d from key if key is configured.
+func durationProperty(get lookup, key string, d *time.Duration) error {
+	v := get(key)
+	if v == "" {
+		return nil
+	}
+	parsed, e := time.ParseDuration(v)
+	if e != nil {
+		return fmt.Errorf("%s: invalid duration %q", key, v)
+	}
+	*d = parsed
+	return nil
+}
+
+func userConfigFile() string {
+	return os.Getenv("HOME") + "/.config/marathonctl/config"
+}
+
+const systemConfigFile = "/etc/marathonctl"
+
+// configAt returns the config file at location, which is location
+// itself for properties or location with a .yaml, .yml or .json
+// extension, preferring the structured formats. It returns "" if
+// there is none.
+func configAt(location string) string {
+	for _, ext := range []string{".yaml", ".yml", ".json", ""} {
+		if _, err := os.Stat(location + ext); err == nil {
+			return location + ext
 		}
 	}
 	return ""
 }
 
-// todo(someday) read $HOME/.config/marathonctl/config
-// Read -config file
-// Then override with cli args
-func Config() (string, string, string, error) {
-	config, host, login, format := cliargs()
+// configFile returns the config file that is edited by default, the
+// per user file if it exists. The system wide one is shared by every
+// user, so it is only edited when named with -c.
+func configFile() string {
+	return configAt(userConfigFile())
+}
 
-	if host != "" && login != "" {
-		return host, login, format, nil
+// configFiles returns the config files that exist, highest
+// precedence first: explicit (from -c), then the per user file,
+// then the system wide one.
+func configFiles(explicit string) []string {
+	var files []string
+	if explicit != "" {
+		files = append(files, explicit)
 	}
-
-	if config == "" {
-		config = configFile()
+	for _, location := range []string{userConfigFile(), systemConfigFile} {
+		if filename := configAt(location); filename != "" {
+			files = append(files, filename)
+		}
 	}
+	return files
+}
 
-	if config != "" {
-		h, l, f, e := readConfigfile(config)
+// ErrNoHost is returned by Config if no Marathon host was given,
+// along with everything else that was configured.
+var ErrNoHost = errors.New("no host info provided")
+
+// Config resolves the settings of marathonctl, each value is taken
+// from the first of
+//
+//	flags
+//	environment variables (MARATHON_HOST, ...)
+//	the -c config file
+//	$HOME/.config/marathonctl/config
+//	/etc/marathonctl
+//	defaults
+//
+// where keys of the active context take precedence over the top
+// level keys of the same config file.
+func Config() (Settings, error) {
+	config, set, cli := cliargs()
+
+	filenames := configFiles(config)
+	var files []layer
+	for _, filename := range filenames {
+		get, e := readConfigfile(filename)
 		if e != nil {
-			return "", "", "", e
-		}
-		if host == "" {
-			host = h
-		}
-		if login == "" {
-			login = l
-		}
-		if format == "" {
-			format = f
-			if format == "" {
-				format = "human"
-			}
+			return Settings{}, e
 		}
+		files = append(files, layer{filename, get})
+	}
+	merged := first(files)
+
+	above := []layer{flagLayer(set), envLayer()}
+	names := contextNames(merged)
+	context := first(above)("marathon.context")
+	if context != "" && !contains(names, context) {
+		return Settings{}, fmt.Errorf("unknown context %q", context)
+	}
+	if context == "" {
+		context = defaultContext(merged, names)
+	}
+
+	sources := Settings{above: above, files: files}
+	s, e := settingsFrom(first(sources.layers(context)))
+	if e != nil {
+		return Settings{}, e
+	}
+	s.File = config
+	if s.File == "" {
+		s.File = configFile()
+	}
+	s.Context = context
+	s.Timeout = cli.Timeout
+	s.Help = cli.Help
+	s.Table = cli.Table
+	if s.Aliases, e = readAliases(filenames); e != nil {
+		return Settings{}, e
 	}
+	s.Options.Trace = cli.Options.Trace
+	s.Options.TraceCurl = cli.Options.TraceCurl
+	s.above = above
+	s.files = files
 
-	if host == "" {
-		return "", "", "", errors.New("no host info provided")
+	if s.Host == "" {
+		return s, ErrNoHost
 	}
 
-	return host, login, format, nil
+	return s, nil
 }
@@ -0,0 +1,95 @@
+package marathon
+
+import "testing"
+
+func mapLayer(name string, keys map[string]string) layer {
+	return layer{name, func(key string) string { return keys[key] }}
+}
+
+func TestResolvePassword(t *testing.T) {
+	const (
+		user     = "marathon.user"
+		password = "marathon.password"
+		file     = "marathon.password_file"
+		command  = "marathon.password_command"
+	)
+	tests := []struct {
+		name               string
+		flags, env, config map[string]string
+		key                string
+		want, from         string
+	}{
+		{
+			name:   "user and password of the config file",
+			config: map[string]string{user: "bob", password: "b"},
+			key:    password, want: "b", from: "config",
+		},
+		{
+			name:   "password file flag for the user of the config file",
+			flags:  map[string]string{file: "/secret"},
+			config: map[string]string{user: "bob", password: "b"},
+			key:    file, want: "/secret", from: "flag",
+		},
+		{
+			name:   "the password of the config file is not mixed with the flag",
+			flags:  map[string]string{file: "/secret"},
+			config: map[string]string{user: "bob", password: "b"},
+			key:    password,
+		},
+		{
+			name:   "environment password for the user of the config file",
+			env:    map[string]string{password: "e"},
+			config: map[string]string{user: "bob"},
+			key:    password, want: "e", from: "env",
+		},
+		{
+			name:   "password command in the environment for a user flag",
+			flags:  map[string]string{user: "alice"},
+			env:    map[string]string{command: "pass show alice"},
+			config: map[string]string{user: "bob", password: "b"},
+			key:    command, want: "pass show alice", from: "env",
+		},
+		{
+			name:   "the password of another user is not sent",
+			flags:  map[string]string{user: "alice"},
+			config: map[string]string{user: "bob", password: "b"},
+			key:    password,
+		},
+		{
+			name:  "a password with a different user is skipped",
+			flags: map[string]string{user: "alice"},
+			env:   map[string]string{user: "carol", password: "c"},
+			key:   password,
+		},
+		{
+			name:   "a lower layer may supply the password of the same user",
+			flags:  map[string]string{user: "bob"},
+			config: map[string]string{user: "bob", command: "pass show bob"},
+			key:    command, want: "pass show bob", from: "config",
+		},
+		{
+			name:   "or a lower layer without a user",
+			flags:  map[string]string{user: "alice"},
+			config: map[string]string{password: "x"},
+			key:    password, want: "x", from: "config",
+		},
+		{
+			name:   "the user comes from the first layer setting one",
+			flags:  map[string]string{password: "p"},
+			env:    map[string]string{user: "alice"},
+			config: map[string]string{user: "bob"},
+			key:    user, want: "alice", from: "env",
+		},
+	}
+	for _, test := range tests {
+		layers := []layer{
+			mapLayer("flag", test.flags),
+			mapLayer("env", test.env),
+			mapLayer("config", test.config),
+		}
+		v, from := resolve(layers, test.key)
+		if v != test.want || from != test.from {
+			t.Errorf("%s: got %q from %q, want %q from %q", test.name, v, from, test.want, test.from)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package marathon
+
+// YAML and JSON config files, which are flattened into the same keys
+// as the properties format:
+//
+//	marathon:
+//	  host: [http://node1:8080, http://node2:8080]
+//	  timeout:
+//	    connect: 5s
+//	  tls:
+//	    ca: /etc/ssl/ca.pem
+//	contexts:
+//	  prod:
+//	    host: https://prod1:8443
+//
+// is read as marathon.host, marathon.timeout.connect, marathon.tls.ca
+// and context.prod.host, with marathon.contexts listing the contexts.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// structured reports whether filename is a YAML or JSON config file.
+func structured(filename string) bool {
+	switch filepath.Ext(filename) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// readStructured reads a YAML or JSON config file into flat keys.
+func readStructured(filename string) (map[string]string, error) {
+	b, e := ioutil.ReadFile(filename)
+	if e != nil {
+		return nil, e
+	}
+	var tree map[string]interface{}
+	if filepath.Ext(filename) == ".json" {
+		e = json.Unmarshal(b, &tree)
+	} else {
+		e = yaml.Unmarshal(b, &tree)
+	}
+	if e != nil {
+		return nil, e
+	}
+	keys := make(map[string]string)
+	var names []string
+	for top, value := range tree {
+		if top != "contexts" {
+			flatten(keys, top, value)
+			continue
+		}
+		contexts, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("contexts must map names to settings")
+		}
+		for name, settings := range contexts {
+			flatten(keys, "context."+name, settings)
+			names = append(names, name)
+		}
+	}
+	if keys["marathon.contexts"] == "" && len(names) > 0 {
+		sort.Strings(names)
+		keys["marathon.contexts"] = strings.Join(names, ",")
+	}
+	return keys, nil
+}
+
+// flatten adds value to keys under prefix, joining the names of
+// nested maps with "." and the items of lists with ",".
+func flatten(keys map[string]string, prefix string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, nested := range v {
+			flatten(keys, prefix+"."+name, nested)
+		}
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, scalar(item))
+		}
+		keys[prefix] = strings.Join(items, ",")
+	default:
+		keys[prefix] = scalar(v)
+	}
+}
+
+func scalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// unflatten turns flat keys back into the tree written to YAML and
+// JSON files, the inverse of readStructured.
+func unflatten(props []property) (map[string]interface{}, error) {
+	tree := make(map[string]interface{})
+	named := make(map[string]bool)
+	for _, p := range props {
+		path := strings.Split(p.Key, ".")
+		if path[0] == "context" && len(path) > 2 {
+			path[0] = "contexts"
+			named[path[1]] = true
+		}
+		node := tree
+		for _, name := range path[:len(path)-1] {
+			next, ok := node[name]
+			if !ok {
+				next = make(map[string]interface{})
+				node[name] = next
+			}
+			if node, ok = next.(map[string]interface{}); !ok {
+				return nil, fmt.Errorf("%s conflicts with another key", p.Key)
+			}
+		}
+		leaf := path[len(path)-1]
+		if _, ok := node[leaf]; ok {
+			return nil, fmt.Errorf("%s conflicts with another key", p.Key)
+		}
+		node[leaf] = typed(p.Key, p.Value)
+	}
+	// marathon.contexts is implied by the contexts
+	if marathon, ok := tree["marathon"].(map[string]interface{}); ok {
+		listed, _ := marathon["contexts"].(string)
+		implied := true
+		for _, name := range strings.Split(listed, ",") {
+			implied = implied && named[strings.TrimSpace(name)]
+		}
+		if implied {
+			delete(marathon, "contexts")
+		}
+	}
+	return tree, nil
+}
+
+// numericSettings and booleanSettings are the settings, named as after "marathon."
+// or "context.NAME.", whose values are written as numbers or booleans.
+// Any other value stays a string, so that a password like 007 is kept.
+var (
+	numericSettings = map[string]bool{"retries": true}
+	booleanSettings = map[string]bool{"roundrobin": true, "tls.insecure": true}
+)
+
+// typed converts a properties value to a bool, number or list where
+// the setting of key means that.
+func typed(key, value string) interface{} {
+	name := strings.TrimPrefix(key, "marathon.")
+	if strings.HasPrefix(key, "context.") {
+		if toks := strings.SplitN(key, ".", 3); len(toks) == 3 {
+			name = toks[2]
+		}
+	}
+	if name == "host" && strings.Contains(value, ",") {
+		var hosts []string
+		for _, host := range strings.Split(value, ",") {
+			hosts = append(hosts, strings.TrimSpace(host))
+		}
+		return hosts
+	}
+	if b, e := strconv.ParseBool(value); e == nil && booleanSettings[name] && (value == "true" || value == "false") {
+		return b
+	}
+	if n, e := strconv.Atoi(value); e == nil && numericSettings[name] {
+		return n
+	}
+	return value
+}
+
+// encodeStructured writes tree in the format of filename.
+func encodeStructured(filename string, tree map[string]interface{}) ([]byte, error) {
+	if filepath.Ext(filename) == ".json" {
+		b, e := json.MarshalIndent(tree, "", "    ")
+		return append(b, '\n'), e
+	}
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if e := enc.Encode(tree); e != nil {
+		return nil, e
+	}
+	e := enc.Close()
+	return b.Bytes(), e
+}
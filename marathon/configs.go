@@ -0,0 +1,511 @@
+package marathon
+
+// All actions under command config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// property is one key of the configuration and where it came from.
+type property struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// properties reads the keys of a config file, in the order they
+// are written for a properties file and sorted otherwise.
+func properties(filename string) ([]property, error) {
+	if structured(filename) {
+		keys, e := readStructured(filename)
+		if e != nil {
+			return nil, e
+		}
+		var props []property
+		for key, value := range keys {
+			props = append(props, property{key, value, filename})
+		}
+		sort.Slice(props, func(i, j int) bool {
+			return props[i].Key < props[j].Key
+		})
+		return props, nil
+	}
+	f, e := os.Open(filename)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+	var props []property
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		props = append(props, property{
+			Key:    strings.TrimSpace(kv[0]),
+			Value:  strings.TrimSpace(kv[1]),
+			Source: filename,
+		})
+	}
+	return props, scanner.Err()
+}
+
+// secret reports whether the value of key should not be shown.
+func secret(key string) bool {
+	for _, s := range settings {
+		if s.secret && strings.HasSuffix(key, "."+strings.TrimPrefix(s.key, "marathon.")) {
+			return true
+		}
+	}
+	return false
+}
+
+func redacted(p property) property {
+	if p.Value != "" && secret(p.Key) {
+		p.Value = "REDACTED"
+	}
+	return p
+}
+
+// view
+type ConfigView struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+func (c ConfigView) flags(resolved *bool) *flag.FlagSet {
+	fs := newFlagSet("config view")
+	fs.BoolVar(resolved, "resolved", false, "show the effective value of each key and where it came from")
+	return fs
+}
+
+func (c ConfigView) Flags() *flag.FlagSet {
+	return c.flags(new(bool))
+}
+
+func (c ConfigView) Apply(ctx context.Context, args []string) {
+	var resolved bool
+	args = flagsOf(c.flags(&resolved), args)
+	check.Check(len(args) == 0, "no arguments")
+	var props []property
+	if resolved {
+		props = c.resolved()
+	} else {
+		props = c.files()
+	}
+	b, e := json.Marshal(props)
+	check.Check(e == nil, "failed to encode config", e)
+	fmt.Println(c.Formats.FormatTable(bytes.NewReader(b), c.Tabulate))
+}
+
+func (c ConfigView) Usage() []Usage {
+	return []Usage{
+		{"", "show the keys of the config files, secrets redacted"},
+	}
+}
+
+// files returns the keys of every config file, highest precedence
+// first.
+func (c ConfigView) files() []property {
+	props := []property{}
+	for _, l := range c.Settings.files {
+		found, e := properties(l.name)
+		check.Check(e == nil, "failed to read config file", e)
+		for _, p := range found {
+			props = append(props, redacted(p))
+		}
+	}
+	check.Check(len(props) > 0, "no config file found")
+	return props
+}
+
+// resolved returns the effective value of each known key.
+func (c ConfigView) resolved() []property {
+	props := []property{}
+	for _, s := range settings {
+		value, source := resolve(c.Settings.layers(c.Settings.Context), s.key)
+		if s.key == "marathon.context" && c.Settings.Context != "" && source == "" {
+			value, source = c.Settings.Context, contextFile()
+		}
+		if source == "" {
+			continue
+		}
+		props = append(props, redacted(property{s.key, value, source}))
+	}
+	return props
+}
+
+func (c ConfigView) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var props []property
+	e := dec.Decode(&props)
+	check.Check(e == nil, "failed to decode config", e)
+	t := newTable("KEY", "SOURCE", "VALUE")
+	for _, p := range props {
+		t.add(p.Key, p.Source, p.Value)
+	}
+	return t
+}
+
+// convert
+type ConfigConvert struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+func (c ConfigConvert) Apply(ctx context.Context, args []string) {
+	format := "yaml"
+	switch len(args) {
+	case 0:
+	case 1:
+		format = args[0]
+	default:
+		check.Check(false, "expected 0 or 1 argument")
+	}
+	check.Check(format == "yaml" || format == "json", "format must be yaml or json")
+	from := c.Settings.File
+	check.Check(from != "", "no config file found")
+	check.Check(!structured(from), from, "is already", strings.TrimPrefix(filepath.Ext(from), "."))
+	to := strings.TrimSuffix(from, filepath.Ext(from)) + "." + format
+	_, e := os.Stat(to)
+	check.Check(os.IsNotExist(e), to, "already exists")
+
+	props, e := properties(from)
+	check.Check(e == nil, "failed to read config file", e)
+	tree, e := unflatten(props)
+	check.Check(e == nil, "failed to convert", from, e)
+	b, e := encodeStructured(to, tree)
+	check.Check(e == nil, "failed to encode", to, e)
+	e = ioutil.WriteFile(to, b, 0600)
+	check.Check(e == nil, "failed to write", to, e)
+
+	b, e = json.Marshal(map[string]string{"from": from, "to": to})
+	check.Check(e == nil, "failed to encode response", e)
+	fmt.Println(c.Formats.FormatTable(bytes.NewReader(b), c.Tabulate))
+}
+
+func (c ConfigConvert) Usage() []Usage {
+	return []Usage{
+		{"", "write the properties config file as config.yaml"},
+		{"[yaml|json]", "write the properties config file as YAML or JSON"},
+	}
+}
+
+func (c ConfigConvert) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return []string{"yaml", "json"}
+	}
+	return nil
+}
+
+func (c ConfigConvert) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var converted map[string]string
+	e := dec.Decode(&converted)
+	check.Check(e == nil, "failed to decode response", e)
+	t := newTable("FROM", "TO")
+	t.add(converted["from"], converted["to"])
+	return t
+}
+
+// init
+type ConfigInit struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+func (c ConfigInit) Apply(ctx context.Context, args []string) {
+	host := "http://localhost:8080"
+	switch len(args) {
+	case 0:
+	case 1:
+		host = args[0]
+	default:
+		check.Check(false, "expected 0 or 1 argument")
+	}
+	filename := userConfigFile()
+	existing := configAt(filename)
+	check.Check(existing == "", existing, "already exists")
+	e := os.MkdirAll(filepath.Dir(filename), 0700)
+	check.Check(e == nil, "failed to create config directory", e)
+	e = ioutil.WriteFile(filename, skeleton(host), 0600)
+	check.Check(e == nil, "failed to write", filename, e)
+	b, e := json.Marshal(configFileName{filename})
+	check.Check(e == nil, "failed to encode file", e)
+	fmt.Println(c.Formats.FormatTable(bytes.NewReader(b), c.Tabulate))
+}
+
+func (c ConfigInit) Usage() []Usage {
+	return []Usage{
+		{"", "write a skeleton ~/.config/marathonctl/config"},
+		{"[host]", "write a skeleton ~/.config/marathonctl/config for host"},
+	}
+}
+
+// skeleton is a properties file setting host, with every other
+// setting commented out.
+func skeleton(host string) []byte {
+	var b bytes.Buffer
+	b.WriteString("# marathonctl configuration, see marathonctl config view --resolved\n")
+	b.WriteString("marathon.host: " + host + "\n")
+	for _, s := range settings {
+		switch s.key {
+		case "marathon.host", "marathon.context", "marathon.contexts":
+			continue
+		}
+		b.WriteString(strings.TrimSpace("# "+s.key+": "+defaults[s.key]) + "\n")
+	}
+	return b.Bytes()
+}
+
+func (c ConfigInit) Tabulate(body io.Reader) Table {
+	var file configFileName
+	e := json.NewDecoder(body).Decode(&file)
+	check.Check(e == nil, "failed to decode file", e)
+	t := newTable("FILE")
+	t.add(file.File)
+	return t
+}
+
+type configFileName struct {
+	File string `json:"file"`
+}
+
+// set
+type ConfigSet struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+func (c ConfigSet) Apply(ctx context.Context, args []string) {
+	check.Check(len(args) == 2, "must specify key and value")
+	key, value := args[0], args[1]
+	check.Check(known(key), "unknown key", key)
+
+	filename := c.Settings.File
+	if filename == "" {
+		filename = userConfigFile()
+		e := os.MkdirAll(filepath.Dir(filename), 0700)
+		check.Check(e == nil, "failed to create config directory", e)
+	}
+	var props []property
+	if _, e := os.Stat(filename); e == nil {
+		props, e = properties(filename)
+		check.Check(e == nil, "failed to read config file", e)
+	}
+
+	// refuse values which would break the configuration
+	get := func(k string) string {
+		if k == key {
+			return value
+		}
+		for _, p := range props {
+			if p.Key == k {
+				return p.Value
+			}
+		}
+		return ""
+	}
+	context := ""
+	if strings.HasPrefix(key, "context.") {
+		context = strings.SplitN(key, ".", 3)[1]
+	}
+	_, e := settingsFrom(inContext(get, context))
+	check.Check(e == nil, e)
+
+	e = setProperty(filename, props, key, value)
+	check.Check(e == nil, "failed to write", filename, e)
+	b, e := json.Marshal(redacted(property{key, value, filename}))
+	check.Check(e == nil, "failed to encode response", e)
+	fmt.Println(c.Formats.FormatTable(bytes.NewReader(b), c.Tabulate))
+}
+
+func (c ConfigSet) Usage() []Usage {
+	return []Usage{
+		{"[key] [value]", "set key in the config file, \"\" removes it"},
+	}
+}
+
+func (c ConfigSet) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		var keys []string
+		for _, s := range settings {
+			keys = append(keys, s.key)
+		}
+		return keys
+	}
+	return nil
+}
+
+// setProperty writes value for key in filename, which has props,
+// removing key if value is "". Properties files are edited in place
+// to keep comments and order.
+func setProperty(filename string, props []property, key, value string) error {
+	if structured(filename) {
+		var kept []property
+		for _, p := range props {
+			if p.Key != key {
+				kept = append(kept, p)
+			}
+		}
+		if value != "" {
+			kept = append(kept, property{key, value, filename})
+		}
+		tree, e := unflatten(kept)
+		if e != nil {
+			return e
+		}
+		b, e := encodeStructured(filename, tree)
+		if e != nil {
+			return e
+		}
+		return ioutil.WriteFile(filename, b, 0600)
+	}
+
+	b, e := ioutil.ReadFile(filename)
+	if e != nil && !os.IsNotExist(e) {
+		return e
+	}
+	var lines []string
+	if len(b) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	}
+	replaced := false
+	var edited []string
+	for _, line := range lines {
+		kv := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(kv) == 2 && !strings.HasPrefix(kv[0], "#") && strings.TrimSpace(kv[0]) == key {
+			if value == "" || replaced {
+				continue
+			}
+			line = key + ": " + value
+			replaced = true
+		}
+		edited = append(edited, line)
+	}
+	if !replaced && value != "" {
+		edited = append(edited, key+": "+value)
+	}
+	return ioutil.WriteFile(filename, []byte(strings.Join(edited, "\n")+"\n"), 0600)
+}
+
+func (c ConfigSet) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var p property
+	e := dec.Decode(&p)
+	check.Check(e == nil, "failed to decode response", e)
+	t := newTable("KEY", "FILE", "VALUE")
+	t.add(p.Key, p.Source, p.Value)
+	return t
+}
+
+// validate
+type ConfigValidate struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+// validation is the outcome of checking one part of the configuration.
+type validation struct {
+	Context string `json:"context"`
+	Target  string `json:"target"`
+	OK      bool   `json:"ok"`
+	Result  string `json:"result"`
+}
+
+func (c ConfigValidate) Apply(ctx context.Context, args []string) {
+	check.Check(len(args) == 0, "no arguments")
+	results := []validation{}
+	for _, file := range c.Settings.files {
+		props, e := properties(file.name)
+		if e != nil {
+			results = append(results, validation{"", file.name, false, e.Error()})
+			continue
+		}
+		for _, p := range props {
+			if !known(p.Key) {
+				results = append(results, validation{"", file.name, false, "unknown key " + p.Key})
+			}
+		}
+	}
+
+	names := contextNames(first(c.Settings.files))
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	for _, name := range names {
+		results = append(results, c.validate(ctx, name)...)
+	}
+	b, e := json.Marshal(results)
+	check.Check(e == nil, "failed to encode response", e)
+	fmt.Println(c.Formats.FormatTable(bytes.NewReader(b), c.Tabulate))
+
+	for _, result := range results {
+		check.Check(result.OK, "configuration is not valid")
+	}
+}
+
+func (c ConfigValidate) Usage() []Usage {
+	return []Usage{
+		{"", "check the config files and ping the hosts of each context"},
+	}
+}
+
+// validate checks the settings of context and pings each of its hosts.
+func (c ConfigValidate) validate(ctx context.Context, context string) []validation {
+	s, e := settingsFrom(first(c.Settings.layers(context)))
+	if e != nil {
+		return []validation{{context, "settings", false, e.Error()}}
+	}
+	if s.Host == "" {
+		return []validation{{context, "marathon.host", false, "not set"}}
+	}
+	client, e := NewClient(NewLogin(s.Host, s.Login, s.Secrets...), s.Options)
+	if e != nil {
+		return []validation{{context, "tls", false, e.Error()}}
+	}
+	var results []validation
+	for _, host := range client.login.Hosts {
+		elapsed, e := client.Ping(ctx, host)
+		if e != nil {
+			results = append(results, validation{context, host, false, describe(e)})
+			continue
+		}
+		results = append(results, validation{context, host, true, "ok " + elapsed.String()})
+	}
+	return results
+}
+
+func (c ConfigValidate) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var results []validation
+	e := dec.Decode(&results)
+	check.Check(e == nil, "failed to decode response", e)
+	t := newTable("CONTEXT", "TARGET", "RESULT")
+	for _, result := range results {
+		context := result.Context
+		if context == "" {
+			context = "-"
+		}
+		t.add(context, result.Target, result.Result)
+	}
+	return t
+}
@@ -0,0 +1,269 @@
+package marathon
+
+// All actions under command context
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// contextFile remembers the context chosen by context use.
+func contextFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "marathonctl", "context")
+}
+
+// chosenContext returns the context chosen by context use, if any.
+func chosenContext() string {
+	b, e := ioutil.ReadFile(contextFile())
+	if e != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// defaultContext returns the context chosen by context use, else the
+// one set by marathon.context in the config files. A context which is
+// not one of names is ignored with a warning, so that a stale choice
+// does not stop every command, including context use.
+func defaultContext(merged lookup, names []string) string {
+	for _, saved := range []struct{ name, from string }{
+		{chosenContext(), contextFile()},
+		{merged("marathon.context"), "marathon.context"},
+	} {
+		if saved.name == "" {
+			continue
+		}
+		if contains(names, saved.name) {
+			return saved.name
+		}
+		fmt.Fprintf(os.Stderr, "warning: ignoring unknown context %q from %s\n", saved.name, saved.from)
+	}
+	return ""
+}
+
+// contexts returns the merged config files and their context names.
+func contexts(s Settings) (lookup, []string) {
+	check.Check(len(s.files) > 0, "no config file found")
+	get := first(s.files)
+	names := contextNames(get)
+	check.Check(len(names) > 0, "no contexts in marathon.contexts")
+	return get, names
+}
+
+type contextInfo struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+	Host    string `json:"host"`
+	User    string `json:"user,omitempty"`
+	Format  string `json:"format,omitempty"`
+	TLS     TLS    `json:"tls"`
+	Auth    string `json:"auth"`
+}
+
+func describeContext(get lookup, name, current string) contextInfo {
+	s, e := settingsFrom(inContext(get, name))
+	check.Check(e == nil, "invalid context", name, e)
+	l := NewLogin(s.Host, s.Login)
+	auth := "none"
+	switch a := s.Options.Auth; {
+	case a.Token != "":
+		auth = "token"
+	case a.TokenFile != "":
+		auth = "token_file " + a.TokenFile
+	case a.TokenCommand != "":
+		auth = "token_command"
+	case a.LoginURL != "":
+		auth = "login_url " + a.LoginURL
+	case l.User != "":
+		auth = "basic"
+	}
+	return contextInfo{
+		Name:    name,
+		Current: name == current,
+		Host:    s.Host,
+		User:    l.User,
+		Format:  s.Format,
+		TLS:     s.Options.TLS,
+		Auth:    auth,
+	}
+}
+
+// list
+type ContextList struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+func (c ContextList) Apply(ctx context.Context, args []string) {
+	check.Check(len(args) == 0, "no arguments")
+	get, names := contexts(c.Settings)
+	infos := []contextInfo{}
+	for _, name := range names {
+		infos = append(infos, describeContext(get, name, c.Settings.Context))
+	}
+	b, e := json.Marshal(infos)
+	check.Check(e == nil, "failed to encode contexts", e)
+	fmt.Println(c.Formats.FormatTable(bytes.NewReader(b), c.Tabulate))
+}
+
+func (c ContextList) Usage() []Usage {
+	return []Usage{
+		{"", "list the contexts of the config file"},
+	}
+}
+
+func (c ContextList) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var infos []contextInfo
+	e := dec.Decode(&infos)
+	check.Check(e == nil, "failed to decode contexts", e)
+	t := newTable("CURRENT", "NAME", "HOST")
+	t.wide("USER", "FORMAT")
+	for _, info := range infos {
+		var current string
+		if info.Current {
+			current = "*"
+		}
+		t.add(current, info.Name, info.Host, info.User, info.Format)
+	}
+	return t
+}
+
+// use
+type ContextUse struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+func (c ContextUse) Apply(ctx context.Context, args []string) {
+	check.Check(len(args) == 1, "must specify context name")
+	_, names := contexts(c.Settings)
+	check.Check(contains(names, args[0]), "unknown context", args[0])
+	e := os.MkdirAll(filepath.Dir(contextFile()), 0700)
+	check.Check(e == nil, "failed to create config directory", e)
+	e = ioutil.WriteFile(contextFile(), []byte(args[0]+"\n"), 0600)
+	check.Check(e == nil, "failed to save context", e)
+	printContextName(c.Formats, args[0], c.Tabulate)
+}
+
+func (c ContextUse) Usage() []Usage {
+	return []Usage{
+		{"[name]", "make context name the current context"},
+	}
+}
+
+func (c ContextUse) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return contextNames(first(c.Settings.files))
+	}
+	return nil
+}
+
+func (c ContextUse) Tabulate(body io.Reader) Table {
+	return tabulateContextName(body)
+}
+
+// current
+type ContextCurrent struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+func (c ContextCurrent) Apply(ctx context.Context, args []string) {
+	check.Check(len(args) == 0, "no arguments")
+	check.Check(c.Settings.Context != "", "no context in use")
+	printContextName(c.Formats, c.Settings.Context, c.Tabulate)
+}
+
+func (c ContextCurrent) Usage() []Usage {
+	return []Usage{
+		{"", "show the name of the current context"},
+	}
+}
+
+func (c ContextCurrent) Tabulate(body io.Reader) Table {
+	return tabulateContextName(body)
+}
+
+type contextName struct {
+	Context string `json:"context"`
+}
+
+func printContextName(f Formatter, name string, tabulate Tabulate) {
+	b, e := json.Marshal(contextName{name})
+	check.Check(e == nil, "failed to encode context", e)
+	fmt.Println(f.FormatTable(bytes.NewReader(b), tabulate))
+}
+
+func tabulateContextName(body io.Reader) Table {
+	var name contextName
+	e := json.NewDecoder(body).Decode(&name)
+	check.Check(e == nil, "failed to decode context", e)
+	t := newTable("CONTEXT")
+	t.add(name.Context)
+	return t
+}
+
+// show
+type ContextShow struct {
+	Settings Settings
+	Formats  Formatter
+}
+
+func (c ContextShow) Apply(ctx context.Context, args []string) {
+	name := c.Settings.Context
+	switch len(args) {
+	case 0:
+		check.Check(name != "", "no context in use")
+	case 1:
+		name = args[0]
+	default:
+		check.Check(false, "expected 0 or 1 argument")
+	}
+	get, names := contexts(c.Settings)
+	check.Check(contains(names, name), "unknown context", name)
+	b, e := json.Marshal(describeContext(get, name, c.Settings.Context))
+	check.Check(e == nil, "failed to encode context", e)
+	fmt.Println(c.Formats.FormatTable(bytes.NewReader(b), c.Tabulate))
+}
+
+func (c ContextShow) Usage() []Usage {
+	return []Usage{
+		{"", "show the settings of the current context"},
+		{"[name]", "show the settings of context name"},
+	}
+}
+
+func (c ContextShow) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return contextNames(first(c.Settings.files))
+	}
+	return nil
+}
+
+func (c ContextShow) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var info contextInfo
+	e := dec.Decode(&info)
+	check.Check(e == nil, "failed to decode context", e)
+	t := newTable("KEY", "VALUE")
+	t.add("name", info.Name)
+	t.add("host", info.Host)
+	t.add("user", info.User)
+	t.add("format", info.Format)
+	t.add("auth", info.Auth)
+	t.add("tls.ca", info.TLS.CA)
+	t.add("tls.cert", info.TLS.Cert)
+	t.add("tls.key", info.TLS.Key)
+	t.add("tls.insecure", fmt.Sprint(info.TLS.Insecure))
+	return t
+}
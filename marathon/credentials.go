@@ -0,0 +1,291 @@
+package marathon
+
+// Passwords kept out of the config file, and the actions under
+// command auth which manage the credentials file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// A Secret supplies a password, it returns "" if it has none.
+type Secret func() (string, error)
+
+// PasswordFile reads the password from the first line of filename.
+func PasswordFile(filename string) Secret {
+	return func() (string, error) {
+		b, e := ioutil.ReadFile(filename)
+		if e != nil {
+			return "", fmt.Errorf("failed to read password file: %v", e)
+		}
+		return firstLine(b), nil
+	}
+}
+
+// PasswordCommand runs command and reads the password from the
+// first line of its output.
+func PasswordCommand(command string) Secret {
+	return func() (string, error) {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stderr = os.Stderr
+		out, e := cmd.Output()
+		if e != nil {
+			return "", fmt.Errorf("password command failed: %v", e)
+		}
+		return firstLine(out), nil
+	}
+}
+
+// StoredPassword looks up the password of user for hosts in the
+// credentials file written by auth set.
+func StoredPassword(user, hosts string) Secret {
+	return func() (string, error) {
+		if _, e := os.Stat(credentialsFile()); os.IsNotExist(e) {
+			return "", nil
+		}
+		credentials, e := loadCredentials()
+		if e != nil {
+			return "", e
+		}
+		return credentials[credentialKey(user, strings.Split(hosts, ","))], nil
+	}
+}
+
+func firstLine(b []byte) string {
+	return strings.TrimRight(strings.SplitN(string(b), "\n", 2)[0], "\r")
+}
+
+func credentialKey(user string, hosts []string) string {
+	return user + "@" + strings.Join(hosts, ",")
+}
+
+// credentialsFile holds the passwords saved by auth set, encrypted
+// with the key in credentialsKeyFile. This keeps the passwords out
+// of config files, backups and screen shares, but anyone able to
+// read both files can decrypt them.
+func credentialsFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "marathonctl", "credentials")
+}
+
+func credentialsKeyFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".config", "marathonctl", "credentials.key")
+}
+
+// credentialsKey returns the key of the credentials file, creating
+// a random one if create is set and there is none yet.
+func credentialsKey(create bool) ([]byte, error) {
+	key, e := ioutil.ReadFile(credentialsKeyFile())
+	if os.IsNotExist(e) && create {
+		key = make([]byte, 32)
+		if _, e := rand.Read(key); e != nil {
+			return nil, fmt.Errorf("failed to create credentials key: %v", e)
+		}
+		if e := os.MkdirAll(filepath.Dir(credentialsKeyFile()), 0700); e != nil {
+			return nil, fmt.Errorf("failed to create config directory: %v", e)
+		}
+		if e := ioutil.WriteFile(credentialsKeyFile(), key, 0600); e != nil {
+			return nil, fmt.Errorf("failed to save credentials key: %v", e)
+		}
+		return key, nil
+	}
+	if e != nil {
+		return nil, fmt.Errorf("failed to read credentials key: %v", e)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("credentials key is corrupt")
+	}
+	return key, nil
+}
+
+func credentialsCipher(create bool) (cipher.AEAD, error) {
+	key, e := credentialsKey(create)
+	if e != nil {
+		return nil, e
+	}
+	block, e := aes.NewCipher(key)
+	if e != nil {
+		return nil, e
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadCredentials decrypts the credentials file, a json object of
+// user@hosts to password.
+func loadCredentials() (map[string]string, error) {
+	credentials := make(map[string]string)
+	sealed, e := ioutil.ReadFile(credentialsFile())
+	if os.IsNotExist(e) {
+		return credentials, nil
+	}
+	if e != nil {
+		return nil, fmt.Errorf("failed to read credentials: %v", e)
+	}
+	aead, e := credentialsCipher(false)
+	if e != nil {
+		return nil, e
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("credentials file is corrupt")
+	}
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	b, e := aead.Open(nil, nonce, sealed, nil)
+	if e != nil {
+		return nil, errors.New("failed to decrypt credentials, wrong key")
+	}
+	if e := json.Unmarshal(b, &credentials); e != nil {
+		return nil, fmt.Errorf("failed to decode credentials: %v", e)
+	}
+	return credentials, nil
+}
+
+func saveCredentials(credentials map[string]string) error {
+	b, e := json.Marshal(credentials)
+	if e != nil {
+		return e
+	}
+	aead, e := credentialsCipher(true)
+	if e != nil {
+		return e
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, e := rand.Read(nonce); e != nil {
+		return e
+	}
+	sealed := aead.Seal(nonce, nonce, b, nil)
+	if e := ioutil.WriteFile(credentialsFile(), sealed, 0600); e != nil {
+		return fmt.Errorf("failed to save credentials: %v", e)
+	}
+	return nil
+}
+
+// readPassword reads a password from stdin, without echo if stdin
+// is a terminal.
+func readPassword(user string) string {
+	info, e := os.Stdin.Stat()
+	terminal := e == nil && info.Mode()&os.ModeCharDevice != 0
+	if terminal {
+		fmt.Fprintf(os.Stderr, "password for %s: ", user)
+		stty("-echo")
+		defer func() {
+			stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	line, e := bufio.NewReader(os.Stdin).ReadString('\n')
+	check.Check(e == nil || e == io.EOF, "failed to read password", e)
+	return strings.TrimRight(line, "\r\n")
+}
+
+func stty(mode string) {
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = os.Stdin
+	cmd.Run()
+}
+
+type storedCredential struct {
+	User  string `json:"user"`
+	Hosts string `json:"hosts"`
+}
+
+// credential returns the key and description of the stored password
+// of the user in args, or the configured user.
+func (c *Client) credential(args []string) (string, storedCredential) {
+	user := c.login.User
+	switch len(args) {
+	case 0:
+		check.Check(user != "", "must specify user, or configure marathon.user")
+	case 1:
+		user = args[0]
+	default:
+		check.Check(false, "expected 0 or 1 argument")
+	}
+	hosts := strings.Join(c.login.Hosts, ",")
+	return credentialKey(user, c.login.Hosts), storedCredential{user, hosts}
+}
+
+func tabulateCredential(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var stored storedCredential
+	e := dec.Decode(&stored)
+	check.Check(e == nil, "failed to decode response", e)
+	t := newTable("USER", "HOSTS")
+	t.add(stored.User, stored.Hosts)
+	return t
+}
+
+// set
+type AuthSet struct {
+	Clients *Client
+	Formats Formatter
+}
+
+func (a AuthSet) Apply(ctx context.Context, args []string) {
+	key, stored := a.Clients.credential(args)
+	password := readPassword(stored.User)
+	check.Check(password != "", "empty password")
+	credentials, e := loadCredentials()
+	check.Check(e == nil, e)
+	credentials[key] = password
+	e = saveCredentials(credentials)
+	check.Check(e == nil, e)
+	b, e := json.Marshal(stored)
+	check.Check(e == nil, "failed to encode response", e)
+	fmt.Println(a.Formats.FormatTable(bytes.NewReader(b), a.Tabulate))
+}
+
+func (a AuthSet) Usage() []Usage {
+	return []Usage{
+		{"", "save the password of the configured user, read from stdin"},
+		{"[user]", "save the password of user, read from stdin"},
+	}
+}
+
+func (a AuthSet) Tabulate(body io.Reader) Table {
+	return tabulateCredential(body)
+}
+
+// remove
+type AuthRemove struct {
+	Clients *Client
+	Formats Formatter
+}
+
+func (a AuthRemove) Apply(ctx context.Context, args []string) {
+	key, stored := a.Clients.credential(args)
+	credentials, e := loadCredentials()
+	check.Check(e == nil, e)
+	_, ok := credentials[key]
+	check.Check(ok, "no stored password for", key)
+	delete(credentials, key)
+	e = saveCredentials(credentials)
+	check.Check(e == nil, e)
+	b, e := json.Marshal(stored)
+	check.Check(e == nil, "failed to encode response", e)
+	fmt.Println(a.Formats.FormatTable(bytes.NewReader(b), a.Tabulate))
+}
+
+func (a AuthRemove) Usage() []Usage {
+	return []Usage{
+		{"", "forget the saved password of the configured user"},
+		{"[user]", "forget the saved password of user"},
+	}
+}
+
+func (a AuthRemove) Tabulate(body io.Reader) Table {
+	return tabulateCredential(body)
+}
@@ -2,11 +2,13 @@ package marathon
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/layneYoo/mCtl/check"
 )
@@ -18,40 +20,42 @@ type DeployList struct {
 	Formats Formatter
 }
 
-func (d DeployList) Apply(args []string) {
+func (d DeployList) Flags() *flag.FlagSet {
+	return listFlagSet("deploy list", &listFlags{})
+}
+
+func (d DeployList) Apply(ctx context.Context, args []string) {
+	args, chosen := listFlagsOf("deploy list", args)
 	check.Check(len(args) == 0, "no arguments")
-	request := d.Clients.GET("/v2/deployments")
-	response, e := d.Clients.Do(request)
-	check.Check(e == nil, "failed to get response")
+	var raw []byte
+	deploys, e := d.Clients.ListDeployments(withRaw(ctx, &raw))
+	d.Formats.Check(e, "failed to list deployments")
+	raw, chosenDeploys := chosen.choose(raw, deploys)
+	fmt.Println(d.Formats.For(chosenDeploys).FormatTable(bytes.NewReader(raw), d.Tabulate))
+}
 
-	defer response.Body.Close()
-	fmt.Println(d.Formats.Format(response.Body, d.Humanize))
+func (d DeployList) Usage() []Usage {
+	return []Usage{
+		{"", "list all active deployments"},
+	}
 }
 
-func (d DeployList) Humanize(body io.Reader) string {
+func (d DeployList) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var deploys Deploys
 	e := dec.Decode(&deploys)
 	check.Check(e == nil, "failed to unmarshal response", e)
-	title := "DEPLOYID VERSION PROGRESS APPS\n"
-	var b bytes.Buffer
+	t := newTable("DEPLOYID", "VERSION", "PROGRESS", "APPS")
+	t.wide("ACTIONS")
 	for _, deploy := range deploys {
-		b.WriteString(deploy.DeployID)
-		b.WriteString(" ")
-		b.WriteString(deploy.Version)
-		b.WriteString(" ")
-		b.WriteString(strconv.Itoa(deploy.CurrentStep))
-		b.WriteString("/")
-		b.WriteString(strconv.Itoa(deploy.TotalSteps))
-		b.WriteString(" ")
-		for _, app := range deploy.AffectedApps {
-			b.WriteString(app)
+		progress := strconv.Itoa(deploy.CurrentStep) + "/" + strconv.Itoa(deploy.TotalSteps)
+		var actions []string
+		for _, step := range deploy.CurrentActions {
+			actions = append(actions, step.Action+" "+step.App)
 		}
-		b.UnreadRune()
-		b.WriteString("\n")
+		t.add(deploy.DeployID, deploy.Version, progress, strings.Join(deploy.AffectedApps, ","), strings.Join(actions, ","))
 	}
-	text := title + b.String()
-	return Columnize(text)
+	return t
 }
 
 type DeployCancel struct {
@@ -59,22 +63,23 @@ type DeployCancel struct {
 	Formats Formatter
 }
 
-func (d DeployCancel) Apply(args []string) {
+func (d DeployCancel) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) == 1, "must supply deployid")
-	deployid := url.QueryEscape(args[0])
-	path := "/v2/deployments/" + deployid
-	request := d.Clients.DELETE(path)
-	response, e := d.Clients.Do(request)
-	check.Check(e == nil, "failed to cancel deploy", e)
-	defer response.Body.Close()
+	var raw []byte
+	rollback, e := d.Clients.CancelDeployment(withRaw(ctx, &raw), args[0])
+	d.Formats.Check(e, "failed to cancel deploy")
+	fmt.Println(d.Formats.For(rollback).FormatTable(bytes.NewReader(raw), tabulateUpdate))
 }
 
-func (d DeployCancel) Humanize(body io.Reader) string {
-	dec := json.NewDecoder(body)
-	var rollback Update
-	e := dec.Decode(&rollback)
-	check.Check(e == nil, "failed to decode response", e)
-	title := "DEPLOYID VERSION\n"
-	text := title + rollback.DeploymentID + " " + rollback.Version
-	return Columnize(text)
+func (d DeployCancel) Usage() []Usage {
+	return []Usage{
+		{"[deployid]", "cancel deployment deployid"},
+	}
+}
+
+func (d DeployCancel) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return d.Clients.deploymentIDs(ctx)
+	}
+	return nil
 }
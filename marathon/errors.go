@@ -0,0 +1,170 @@
+package marathon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Exit codes of marathonctl, scripts may branch on these.
+const (
+	ExitOK          = 0
+	ExitError       = 1   // anything not listed below, including bad usage
+	ExitNotFound    = 2   // the app, group, task, deployment or artifact does not exist
+	ExitConflict    = 3   // already exists, or locked by a deployment in progress
+	ExitInvalid     = 4   // Marathon rejected the request as invalid
+	ExitAuth        = 5   // missing or rejected credentials
+	ExitUnreachable = 6   // no Marathon host could be reached
+	ExitTimeout     = 124 // -timeout expired
+	ExitInterrupted = 130 // SIGINT or SIGTERM
+)
+
+// exitCodes explain the exit codes, for help.
+var exitCodes = map[int]string{
+	ExitOK:          "success",
+	ExitError:       "error, including bad usage",
+	ExitNotFound:    "not found",
+	ExitConflict:    "conflict (already exists, or locked by a deployment)",
+	ExitInvalid:     "invalid request (rejected by Marathon validation)",
+	ExitAuth:        "authentication or authorization failed",
+	ExitUnreachable: "Marathon unreachable",
+	ExitTimeout:     "-timeout expired",
+	ExitInterrupted: "interrupted by SIGINT or SIGTERM",
+}
+
+var (
+	// ErrUnreachable is wrapped by errors caused by no host answering.
+	ErrUnreachable = errors.New("marathon unreachable")
+
+	// ErrAuth is wrapped by errors caused by failing to obtain credentials.
+	ErrAuth = errors.New("authentication failed")
+)
+
+// APIError is returned when Marathon answers with a status code
+// other than 2xx. Message and Details are taken from the error body
+// Marathon sends, if any.
+type APIError struct {
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	StatusCode int           `json:"status"`
+	Message    string        `json:"message"`
+	Details    []ErrorDetail `json:"details,omitempty"`
+	Body       []byte        `json:"-"`
+}
+
+// ErrorDetail is a validation error of one field of a request.
+type ErrorDetail struct {
+	Path   string   `json:"path"`
+	Errors []string `json:"errors"`
+}
+
+func newAPIError(method, path string, status int, body []byte) *APIError {
+	e := &APIError{
+		Method:     method,
+		Path:       path,
+		StatusCode: status,
+		Body:       body,
+	}
+	var decoded struct {
+		Message string        `json:"message"`
+		Details []ErrorDetail `json:"details"`
+		// older versions of Marathon
+		Errors []struct {
+			Attribute string `json:"attribute"`
+			Error     string `json:"error"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &decoded) == nil {
+		e.Message = decoded.Message
+		e.Details = decoded.Details
+		for _, old := range decoded.Errors {
+			e.Details = append(e.Details, ErrorDetail{old.Attribute, []string{old.Error}})
+		}
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	if e.Message == "" {
+		// an empty body, or JSON without a message
+		e.Message = http.StatusText(status)
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (status %d)", e.Message, e.StatusCode)
+	for _, detail := range e.Details {
+		for _, err := range detail.Errors {
+			fmt.Fprintf(&b, "\n  %s: %s", detail.Path, err)
+		}
+	}
+	return b.String()
+}
+
+// ExitCode returns the process exit code documented for e.
+func ExitCode(e error) int {
+	var api *APIError
+	switch {
+	case e == nil:
+		return ExitOK
+	case errors.Is(e, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(e, context.DeadlineExceeded):
+		return ExitTimeout
+	case errors.Is(e, ErrUnreachable):
+		return ExitUnreachable
+	case errors.Is(e, ErrAuth):
+		return ExitAuth
+	case errors.As(e, &api):
+		switch api.StatusCode {
+		case 404:
+			return ExitNotFound
+		case 409, 423:
+			return ExitConflict
+		case 400, 422:
+			return ExitInvalid
+		case 401, 403:
+			return ExitAuth
+		case 502, 503, 504:
+			return ExitUnreachable
+		}
+	}
+	return ExitError
+}
+
+// Check does nothing if e is nil, otherwise it prints what failed
+// on stderr, as JSON or YAML if one of those formats was chosen, and
+// exits with the code matching e.
+func (f Formatter) Check(e error, what string) {
+	if e == nil {
+		return
+	}
+	code := ExitCode(e)
+	switch f.format {
+	case Json, JsonPP, Yaml:
+		out := map[string]interface{}{
+			"error":    what,
+			"exitCode": code,
+		}
+		var api *APIError
+		if errors.As(e, &api) {
+			out["api"] = api
+		} else {
+			out["message"] = e.Error()
+		}
+		b, _ := json.Marshal(out)
+		if f.format == Yaml {
+			fmt.Fprintln(os.Stderr, f.Yamlize(bytes.NewReader(b)))
+		} else {
+			fmt.Fprintln(os.Stderr, string(b))
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "%s: %v\n", what, e)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,27 @@
+package marathon
+
+import "testing"
+
+func TestAPIErrorMessage(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{503, ``, "Service Unavailable (status 503)"},
+		{503, "  \n", "Service Unavailable (status 503)"},
+		{404, `{}`, "Not Found (status 404)"},
+		{404, `{"message":"App '/a' does not exist"}`, "App '/a' does not exist (status 404)"},
+		{502, `<html>bad gateway</html>`, "<html>bad gateway</html> (status 502)"},
+		{422, `{"message":"Object is not valid","details":[{"path":"/id","errors":["error.pattern"]}]}`,
+			"Object is not valid (status 422)\n  /id: error.pattern"},
+		{422, `{"errors":[{"attribute":"id","error":"is invalid"}]}`,
+			"Unprocessable Entity (status 422)\n  id: is invalid"},
+	}
+	for _, test := range tests {
+		e := newAPIError("GET", "/v2/apps", test.status, []byte(test.body))
+		if got := e.Error(); got != test.want {
+			t.Errorf("%d %q: got %q, want %q", test.status, test.body, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package marathon
+
+// Flags of actions, given anywhere after the action name
+
+import (
+	"flag"
+	"io/ioutil"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// flagged is implemented by actions which take flags. Flags returns
+// a FlagSet describing them, for help and completion; Apply parses
+// the flags into its own values with flagsOf.
+type flagged interface {
+	Flags() *flag.FlagSet
+}
+
+// newFlagSet returns an empty FlagSet for the action called name,
+// which reports errors through parseFlags rather than printing.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+// parseFlags parses the flags of fs found anywhere in args, as in
+// "/x --instances 3 --wait", and returns the other arguments in
+// order. Everything after "--" is an argument.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for len(args) > 0 {
+		if e := fs.Parse(args); e != nil {
+			return nil, e
+		}
+		rest := fs.Args()
+		// fs.Parse stops at the first argument, or just after "--"
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	return positional, nil
+}
+
+// flagsOf parses the flags of fs in args, exiting on a bad flag.
+func flagsOf(fs *flag.FlagSet, args []string) []string {
+	positional, e := parseFlags(fs, args)
+	check.Check(e == nil, fs.Name()+":", e)
+	return positional
+}
+
+// flagNames returns the flags of fs as they are typed.
+func flagNames(fs *flag.FlagSet) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "--"+f.Name)
+	})
+	return names
+}
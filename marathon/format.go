@@ -1,41 +1,87 @@
 package marathon
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"sort"
 	"strings"
+	"text/template"
 
 	"github.com/layneYoo/mCtl/check"
+	"gopkg.in/yaml.v3"
 )
 
 type Format int
 
 const (
 	Human Format = iota
+	Wide
 	Json
 	JsonPP
 	Raw
+	Yaml
+	Csv
+	Tsv
+	Template
+	JsonPath
 )
 
+// formats are the names accepted by NewFormatter, for help.
+var formats = []struct {
+	name    string
+	summary string
+}{
+	{"human", "simplified columns, default"},
+	{"wide", "human with more columns"},
+	{"json", "json on one line"},
+	{"jsonpp", "json pretty printed"},
+	{"raw", "the exact response from Marathon"},
+	{"yaml", "yaml with keys sorted"},
+	{"csv", "comma separated values of tables, human for other commands"},
+	{"tsv", "tab separated values of tables, human for other commands"},
+	{"template=TEMPLATE", "Go text/template run against the response, see README"},
+	{"template-file=FILE", "the same with the template read from FILE"},
+	{"jsonpath=EXPR", "the values selected by a JSONPath expression, see README"},
+}
+
 type Humanize func(input io.Reader) string
 
 type Formatter struct {
-	format Format
+	format   Format
+	template *template.Template // of the template format
+	query    jsonpath           // of the jsonpath format
+	table    TableOptions       // of the formats showing tables
+	value    interface{}        // the typed response, see For
 }
 
 func NewFormatter(f string) Formatter {
-	switch f {
-	case "jsonpp":
-		return Formatter{JsonPP}
-	case "json":
-		return Formatter{Json}
-	case "raw":
-		return Formatter{Raw}
+	switch {
+	case f == "wide":
+		return Formatter{format: Wide}
+	case f == "jsonpp":
+		return Formatter{format: JsonPP}
+	case f == "json":
+		return Formatter{format: Json}
+	case f == "raw":
+		return Formatter{format: Raw}
+	case f == "yaml":
+		return Formatter{format: Yaml}
+	case f == "csv":
+		return Formatter{format: Csv}
+	case f == "tsv":
+		return Formatter{format: Tsv}
+	case strings.HasPrefix(f, "template="):
+		return Formatter{format: Template, template: parseTemplate(strings.TrimPrefix(f, "template="), false)}
+	case strings.HasPrefix(f, "template-file="):
+		return Formatter{format: Template, template: parseTemplate(strings.TrimPrefix(f, "template-file="), true)}
+	case strings.HasPrefix(f, "jsonpath="):
+		query, e := parseJSONPath(strings.TrimPrefix(f, "jsonpath="))
+		check.Check(e == nil, e)
+		return Formatter{format: JsonPath, query: query}
 	default:
-		return Formatter{Human}
+		return Formatter{format: Human}
 	}
 }
 
@@ -47,6 +93,12 @@ func (f Formatter) Format(input io.Reader, h Humanize) string {
 		return f.Jsonize(input)
 	case Raw:
 		return f.Raw(input)
+	case Yaml:
+		return f.Yamlize(input)
+	case Template:
+		return f.Templatize(input)
+	case JsonPath:
+		return f.Jsonpathize(input)
 	default:
 		return h(input)
 	}
@@ -77,47 +129,45 @@ func (f Formatter) Raw(input io.Reader) string {
 	return string(b)
 }
 
-const maxCols = 100
-
-// Columnize will pretty print columns of information
-// rows are by newline
-// columns are by whitespace
-func Columnize(text string) string {
-	scanner := bufio.NewScanner(strings.NewReader(text))
-	all := [][]string{}
-	longests := [maxCols]int{} // index=col, val=maxlength
-
-	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
-		for i, field := range fields {
-			if len(field) > longests[i] {
-				longests[i] = len(field)
-			}
-		}
-
-		all = append(all, fields)
+// Yamlize converts the JSON response to YAML, with the keys of each
+// object sorted so the output is the same for the same content.
+// Responses which are not JSON are returned unmodified.
+func (f Formatter) Yamlize(input io.Reader) string {
+	b, e := ioutil.ReadAll(input)
+	check.Check(e == nil, "failed to read input", e)
+	var doc yaml.Node
+	if !json.Valid(b) || yaml.Unmarshal(b, &doc) != nil || doc.Kind == 0 {
+		return string(b)
 	}
-	e := scanner.Err()
-	check.Check(e == nil, "scanner error", e)
-	return strings.TrimSpace(fmtFields(longests, all))
+	blockStyle(&doc)
+	var s bytes.Buffer
+	enc := yaml.NewEncoder(&s)
+	enc.SetIndent(2)
+	e = enc.Encode(&doc)
+	check.Check(e == nil, "failed to encode yaml", e)
+	enc.Close()
+	return strings.TrimSuffix(s.String(), "\n")
 }
 
-func fmtFields(longests [maxCols]int, matrix [][]string) string {
-	var b bytes.Buffer
-	for _, fields := range matrix {
-		for col, field := range fields {
-			b.WriteString(pad(longests[col], field))
-		}
-		b.WriteString("\n")
+// blockStyle clears the flow style and quoting that n has as JSON,
+// and sorts the keys of its mappings.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
 	}
-	return b.String()
-}
-
-func pad(length int, text string) string {
-	var b bytes.Buffer
-	b.WriteString(text)
-	for i := 0; i < (2+length)-len(text); i++ {
-		b.WriteString(" ")
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	pairs := make([][2]*yaml.Node, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		pairs = append(pairs, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i][0].Value < pairs[j][0].Value
+	})
+	n.Content = n.Content[:0]
+	for _, pair := range pairs {
+		n.Content = append(n.Content, pair[0], pair[1])
 	}
-	return b.String()
 }
@@ -4,12 +4,14 @@ package marathon
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/layneYoo/mCtl/check"
 )
@@ -19,56 +21,88 @@ type GroupList struct {
 	Formats Formatter
 }
 
-func (g GroupList) Apply(args []string) {
+func (g GroupList) Flags() *flag.FlagSet {
+	return listFlagSet("group list", &listFlags{})
+}
+
+func (g GroupList) Apply(ctx context.Context, args []string) {
+	args, chosen := listFlagsOf("group list", args)
 	switch len(args) {
 	case 0:
-		g.listGroups("")
+		g.listGroups(ctx, "", chosen)
 	case 1:
-		g.listGroups(args[0])
+		g.listGroups(ctx, args[0], chosen)
 	default:
 		check.Check(false, "expected 0 or 1 argument")
 	}
 }
 
-func (g GroupList) listGroups(groupid string) {
-	path := "/v2/groups"
-	if groupid != "" {
-		path += "/" + url.QueryEscape(groupid)
+func (g GroupList) Usage() []Usage {
+	return []Usage{
+		{"", "list all groups"},
+		{"[groupid]", "list groups in groupid"},
+	}
+}
+
+func (g GroupList) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return g.Clients.groupIDs(ctx)
 	}
-	request := g.Clients.GET(path)
-	response, e := g.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-	fmt.Println(g.Formats.Format(response.Body, g.Humanize))
+	return nil
 }
 
-func (g GroupList) Humanize(body io.Reader) string {
+// listGroups shows the tree of groups under groupid, or the list of
+// groups chosen by the list flags.
+func (g GroupList) listGroups(ctx context.Context, groupid string, chosen selection) {
+	var raw []byte
+	group, e := g.Clients.GetGroup(withRaw(ctx, &raw), groupid)
+	g.Formats.Check(e, "failed to list groups")
+	if chosen.empty() {
+		fmt.Println(g.Formats.For(group).FormatTable(bytes.NewReader(raw), g.Tabulate))
+		return
+	}
+	raw, groups := chosen.chooseTree(raw, []*Group(nil), "groups")
+	fmt.Println(g.Formats.For(groups).FormatTable(bytes.NewReader(raw), g.TabulateList))
+}
+
+func (g GroupList) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var root Group
 	e := dec.Decode(&root)
 	check.Check(e == nil, "failed to unmarshal response", e)
-	return columnizeGroup(&root)
-}
-
-func columnizeGroup(group *Group) string {
-	title := "GROUPID VERSION GROUPS APPS\n"
-	var b bytes.Buffer
-	gatherGroup(group, &b)
-	text := title + b.String()
-	return Columnize(text)
-}
-
-func gatherGroup(g *Group, b *bytes.Buffer) {
-	b.WriteString(g.GroupID)
-	b.WriteString(" ")
-	b.WriteString(g.Version)
-	b.WriteString(" ")
-	b.WriteString(strconv.Itoa(len(g.Groups)))
-	b.WriteString(" ")
-	b.WriteString(strconv.Itoa(len(g.Apps)))
-	b.WriteString("\n")
+	t := groupTable()
+	gatherGroup(&root, &t)
+	return t
+}
+
+// TabulateList is Tabulate for a list of groups, leaving out the
+// groups under them.
+func (g GroupList) TabulateList(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var groups []*Group
+	e := dec.Decode(&groups)
+	check.Check(e == nil, "failed to unmarshal response", e)
+	t := groupTable()
+	for _, group := range groups {
+		addGroup(group, &t)
+	}
+	return t
+}
+
+func groupTable() Table {
+	t := newTable("GROUPID", "VERSION", "GROUPS", "APPS")
+	t.wide("DEPENDENCIES")
+	return t
+}
+
+func addGroup(g *Group, t *Table) {
+	t.add(g.GroupID, g.Version, strconv.Itoa(len(g.Groups)), strconv.Itoa(len(g.Apps)), strings.Join(g.Dependencies, ","))
+}
+
+func gatherGroup(g *Group, t *Table) {
+	addGroup(g, t)
 	for _, group := range g.Groups {
-		gatherGroup(group, b)
+		gatherGroup(group, t)
 	}
 }
 
@@ -77,27 +111,21 @@ type GroupCreate struct {
 	Formats Formatter
 }
 
-func (g GroupCreate) Apply(args []string) {
+func (g GroupCreate) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) == 1, "must supply 1 jsonfile")
 	f, e := os.Open(args[0])
 	check.Check(e == nil, "failed to open jsonfile", e)
 	defer f.Close()
-	request := g.Clients.POST("/v2/groups", f)
-	response, e := g.Clients.Do(request)
-	check.Check(e == nil, "failed to get response")
-	defer response.Body.Close()
-	check.Check(response.StatusCode != 409, "group already exists")
-	fmt.Println(g.Formats.Format(response.Body, g.Humanize))
+	var raw []byte
+	update, e := g.Clients.CreateGroupFrom(withRaw(ctx, &raw), f)
+	g.Formats.Check(e, "failed to create group")
+	fmt.Println(g.Formats.For(update).FormatTable(bytes.NewReader(raw), tabulateUpdate))
 }
 
-func (g GroupCreate) Humanize(body io.Reader) string {
-	dec := json.NewDecoder(body)
-	var update Update
-	e := dec.Decode(&update)
-	check.Check(e == nil, "failed to decode response", e)
-	title := "DEPLOYID VERSION\n"
-	text := title + update.DeploymentID + " " + update.Version
-	return Columnize(text)
+func (g GroupCreate) Usage() []Usage {
+	return []Usage{
+		{"[jsonfile]", "create a group defined in jsonfile"},
+	}
 }
 
 type GroupDestroy struct {
@@ -105,29 +133,37 @@ type GroupDestroy struct {
 	Formats Formatter
 }
 
-func (g GroupDestroy) Apply(args []string) {
+func (g GroupDestroy) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) == 1, "must specify groupid")
-	groupid := url.QueryEscape(args[0])
-	path := "/v2/groups/" + groupid
-	request := g.Clients.DELETE(path)
-	response, e := g.Clients.Do(request)
-	check.Check(e == nil, "destroy group failed", e)
-	defer response.Body.Close()
-	c := response.StatusCode
-	check.Check(c != 404, "unknown group")
-	check.Check(c == 200, "destroy group bad status", c)
+	var raw []byte
+	update, e := g.Clients.DestroyGroup(withRaw(ctx, &raw), args[0])
+	g.Formats.Check(e, "destroy group failed")
+	fmt.Println(g.Formats.For(update).FormatTable(bytes.NewReader(raw), g.Tabulate))
+}
+
+func (g GroupDestroy) Usage() []Usage {
+	return []Usage{
+		{"[groupid]", "destroy group groupid"},
+	}
+}
 
-	fmt.Println(g.Formats.Format(response.Body, g.Humanize))
+func (g GroupDestroy) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return g.Clients.groupIDs(ctx)
+	}
+	return nil
 }
 
-func (g GroupDestroy) Humanize(body io.Reader) string {
+func (g GroupDestroy) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var versionmap map[string]string // ugh
 	e := dec.Decode(&versionmap)
 	check.Check(e == nil, "failed to decode response", e)
 	v, ok := versionmap["version"]
 	check.Check(ok, "version missing")
-	return "VERSION\n" + v
+	t := newTable("VERSION")
+	t.add(v)
+	return t
 }
 
 type GroupUpdate struct {
@@ -135,29 +171,26 @@ type GroupUpdate struct {
 	Formats Formatter
 }
 
-func (g GroupUpdate) Apply(args []string) {
+func (g GroupUpdate) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) == 2, "must specify groupid and jsonfile")
-	groupid := url.QueryEscape(args[0])
 	f, e := os.Open(args[1])
 	check.Check(e == nil, "failed to open jsonfile", e)
 	defer f.Close()
-	request := g.Clients.PUT("/v2/groups/"+groupid, f)
-	response, e := g.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-
-	sc := response.StatusCode
-	check.Check(sc == 200, "bad status code", sc)
+	var raw []byte
+	update, e := g.Clients.UpdateGroupFrom(withRaw(ctx, &raw), args[0], f)
+	g.Formats.Check(e, "failed to update group")
+	fmt.Println(g.Formats.For(update).FormatTable(bytes.NewReader(raw), tabulateUpdate))
+}
 
-	fmt.Println(g.Formats.Format(response.Body, g.Humanize))
+func (g GroupUpdate) Usage() []Usage {
+	return []Usage{
+		{"[groupid] [jsonfile]", "update group groupid as defined in jsonfile"},
+	}
 }
 
-func (g GroupUpdate) Humanize(body io.Reader) string {
-	dec := json.NewDecoder(body)
-	var update Update
-	e := dec.Decode(&update)
-	check.Check(e == nil, "failed to decode response", e)
-	title := "DEPLOYID VERSION\n"
-	text := title + update.DeploymentID + " " + update.Version
-	return Columnize(text)
+func (g GroupUpdate) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return g.Clients.groupIDs(ctx)
+	}
+	return nil
 }
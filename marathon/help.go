@@ -0,0 +1,166 @@
+package marathon
+
+// Help generated from the commands of a Tool
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Usage describes one form of an action, for help.
+type Usage struct {
+	Args    string // arguments, such as "[id] [version]"
+	Summary string
+}
+
+// wantsHelp reports whether the first of args is -help or --help,
+// asking for help instead of running. Later arguments, and those
+// after "--", are left to the action, as is an app named help.
+func wantsHelp(args []string) bool {
+	return len(args) > 0 && (args[0] == "--help" || args[0] == "-help")
+}
+
+// helpAction reports whether the words after a command ask for its
+// help, as in "app help" or "app --help".
+func helpAction(args []string) bool {
+	return len(args) == 0 || args[0] == "help" || wantsHelp(args)
+}
+
+// Help returns the help of every command.
+func (t *Tool) Help() string {
+	var b bytes.Buffer
+	b.WriteString("marathonctl <flags...> [command] [action] <args...>\n")
+	b.WriteString(" Commands\n")
+	for i, name := range t.names() {
+		category, ok := t.Selections[name].(*Category)
+		if !ok {
+			continue
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("    " + name)
+		if category.Summary != "" {
+			b.WriteString(" - " + category.Summary)
+		}
+		b.WriteString("\n")
+		b.WriteString(category.actionsHelp("       "))
+	}
+	b.WriteString(flagsHelp())
+	b.WriteString(formatsHelp())
+	b.WriteString(exitCodesHelp())
+	return b.String()
+}
+
+// Usage prints the help of every command and exits.
+func (t *Tool) Usage() {
+	fmt.Fprint(os.Stderr, t.Help())
+	os.Exit(ExitError)
+}
+
+// Help returns the help of category name.
+func (c *Category) Help(name string) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "marathonctl <flags...> %s [action] <args...>\n", name)
+	if c.Summary != "" {
+		b.WriteString("  " + c.Summary + "\n")
+	}
+	b.WriteString(" Actions\n")
+	b.WriteString(c.actionsHelp("    "))
+	return b.String()
+}
+
+// actionsHelp lists each form of each action, with the summaries
+// lined up.
+func (c *Category) actionsHelp(indent string) string {
+	var forms []string
+	var summaries []string
+	for _, name := range c.names() {
+		for _, usage := range c.Actions[name].Usage() {
+			forms = append(forms, strings.TrimSpace(name+" "+usage.Args))
+			summaries = append(summaries, usage.Summary)
+		}
+	}
+	return aligned(indent, forms, summaries)
+}
+
+// actionHelp returns the help of action name of category.
+func actionHelp(category, name string, action Action) string {
+	var b bytes.Buffer
+	var forms []string
+	var summaries []string
+	for _, usage := range action.Usage() {
+		forms = append(forms, strings.TrimSpace("marathonctl <flags...> "+category+" "+name+" "+usage.Args))
+		summaries = append(summaries, usage.Summary)
+	}
+	b.WriteString(aligned("", forms, summaries))
+	if f, ok := action.(flagged); ok {
+		b.WriteString("\n Flags\n")
+		b.WriteString(flagLines(f.Flags(), "--"))
+	}
+	return b.String()
+}
+
+func aligned(indent string, forms, summaries []string) string {
+	width := 0
+	for _, form := range forms {
+		if len(form) > width {
+			width = len(form)
+		}
+	}
+	var b bytes.Buffer
+	for i, form := range forms {
+		fmt.Fprintf(&b, "%s%-*s - %s\n", indent, width, form, summaries[i])
+	}
+	return b.String()
+}
+
+// flagsHelp lists the global flags.
+func flagsHelp() string {
+	return "\n Flags\n" + flagLines(flag.CommandLine, "-")
+}
+
+// flagLines lists the flags of fs, each written with dash.
+func flagLines(fs *flag.FlagSet, dash string) string {
+	var forms []string
+	var summaries []string
+	fs.VisitAll(func(f *flag.Flag) {
+		placeholder, usage := flag.UnquoteUsage(f)
+		form := dash + f.Name
+		if placeholder != "" {
+			form += " [" + placeholder + "]"
+		}
+		forms = append(forms, form)
+		summaries = append(summaries, usage)
+	})
+	return aligned("  ", forms, summaries)
+}
+
+func formatsHelp() string {
+	var forms []string
+	var summaries []string
+	for _, f := range formats {
+		forms = append(forms, f.name)
+		summaries = append(summaries, f.summary)
+	}
+	return "\n Formats (-f)\n" + aligned("  ", forms, summaries)
+}
+
+func exitCodesHelp() string {
+	var codes []int
+	for code := range exitCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	var forms []string
+	var summaries []string
+	for _, code := range codes {
+		forms = append(forms, fmt.Sprint(code))
+		summaries = append(summaries, exitCodes[code])
+	}
+	return "\n Exit Codes\n" + aligned("  ", forms, summaries)
+}
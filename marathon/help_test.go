@@ -0,0 +1,37 @@
+package marathon
+
+import "testing"
+
+func TestWantsHelp(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"help"}, false},
+		{[]string{"-help"}, true},
+		{[]string{"--help", "/a"}, true},
+		{[]string{"/help"}, false},
+		{[]string{"/a", "help"}, false},
+		{[]string{"--", "help"}, false},
+		{[]string{"team", "--help"}, false},
+	}
+	for _, test := range tests {
+		if got := wantsHelp(test.args); got != test.want {
+			t.Errorf("%q: got %v, want %v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestHelpAction(t *testing.T) {
+	for _, args := range [][]string{nil, {"help"}, {"--help"}, {"-help", "list"}} {
+		if !helpAction(args) {
+			t.Errorf("%q: expected help", args)
+		}
+	}
+	for _, args := range [][]string{{"list"}, {"list", "help"}, {"--", "help"}} {
+		if helpAction(args) {
+			t.Errorf("%q: expected no help", args)
+		}
+	}
+}
@@ -34,7 +34,7 @@ type Tasks struct {
 }
 
 type Application struct {
-	ID              string              `json:"id"`
+	ID              string              `json:"id,omitempty"`
 	Cmd             string              `json:"cmd,omitempty"`
 	Args            []string            `json:"args,omitempty"`
 	Constraints     [][]string          `json:"constraints,omitempty"`
@@ -43,8 +43,8 @@ type Application struct {
 	Disk            float64             `json:"disk,omitempty"`
 	Env             map[string]string   `json:"env,omitempty"`
 	Executor        string              `json:"executor,omitempty"`
-	Healthcheck     []*Healthcheck      `json:"healthcheck,omitempty"`
-	Instances       int                 `json:"instances,omitemptys"`
+	Healthcheck     []*Healthcheck      `json:"healthChecks,omitempty"`
+	Instances       int                 `json:"instances,omitempty"`
 	Mem             float64             `json:"mem,omitempty"`
 	Tasks           []*Task             `json:"tasks,omitempty"`
 	Ports           []int               `json:"ports,omitempty"`
@@ -0,0 +1,730 @@
+package marathon
+
+// The jsonpath format, -f jsonpath=EXPR, which prints the parts of the
+// JSON response selected by EXPR, one per line:
+//
+//	$.apps[*].id                      the id of every app
+//	.apps[?(@.instances > 0)].id      the ids of the apps with instances
+//	..tasks[*].host                   the hosts of tasks at any depth
+//	.apps[0:2]['id','cmd']            id and cmd of the first two apps
+//	.apps[*].{id, n: instances}       objects of the chosen fields
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// jsonpath is a parsed expression, applied one selector at a time.
+type jsonpath []selector
+
+// selector returns the values it selects from v.
+type selector func(v interface{}) []interface{}
+
+// predicate decides whether a filter keeps v.
+type predicate func(v interface{}) bool
+
+// operand is a value of a filter, and whether there is one.
+type operand func(v interface{}) (interface{}, bool)
+
+// eval returns the values path selects from root.
+func (path jsonpath) eval(root interface{}) []interface{} {
+	values := []interface{}{root}
+	for _, selector := range path {
+		var next []interface{}
+		for _, v := range values {
+			next = append(next, selector(v)...)
+		}
+		values = next
+	}
+	return values
+}
+
+// Jsonpathize prints the values the jsonpath of f selects from the
+// response: strings and numbers as they are, anything else as JSON.
+func (f Formatter) Jsonpathize(input io.Reader) string {
+	b, e := ioutil.ReadAll(input)
+	check.Check(e == nil, "failed to read input", e)
+	var root interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if dec.Decode(&root) != nil {
+		root = string(b)
+	}
+	var lines []string
+	for _, v := range f.query.eval(root) {
+		switch v := v.(type) {
+		case string:
+			lines = append(lines, v)
+		case json.Number:
+			lines = append(lines, v.String())
+		default:
+			b, e := json.Marshal(v)
+			check.Check(e == nil, "failed to encode", e)
+			lines = append(lines, string(b))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseJSONPath parses expr, where the leading $ may be left out.
+func parseJSONPath(expr string) (jsonpath, error) {
+	p := &pathParser{expr: strings.TrimSpace(expr)}
+	p.consume("$")
+	path, e := p.segments(true)
+	if e != nil {
+		return nil, e
+	}
+	if p.pos < len(p.expr) {
+		return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+	}
+	return path, nil
+}
+
+type pathParser struct {
+	expr string
+	pos  int
+}
+
+func (p *pathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("jsonpath %s: at %d: %s", p.expr, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *pathParser) peek() byte {
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+// consume skips s if it comes next.
+func (p *pathParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) skipSpace() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+func (p *pathParser) expect(s string) error {
+	p.skipSpace()
+	if !p.consume(s) {
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// name parses a key written without quotes.
+func (p *pathParser) name() (string, error) {
+	start := p.pos
+	for isNameByte(p.peek()) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", p.errorf("expected a name")
+	}
+	return p.expr[start:p.pos], nil
+}
+
+// quoted parses a string in single or double quotes.
+func (p *pathParser) quoted() (string, error) {
+	quote := p.peek()
+	end := strings.IndexByte(p.expr[p.pos+1:], quote)
+	if end < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.expr[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return s, nil
+}
+
+// segments parses the selectors of a path, which may start with a
+// bare name if leading is set, as in apps[*].id.
+func (p *pathParser) segments(leading bool) (jsonpath, error) {
+	var path jsonpath
+	if leading && isNameByte(p.peek()) {
+		name, _ := p.name()
+		path = append(path, child(name))
+	}
+	for {
+		switch {
+		case p.consume(".."):
+			if p.consume("*") {
+				path = append(path, descendants(""))
+				continue
+			}
+			name, e := p.name()
+			if e != nil {
+				return nil, e
+			}
+			path = append(path, descendants(name))
+		case p.consume("."):
+			switch {
+			case p.consume("*"):
+				path = append(path, wildcard)
+			case p.consume("{"):
+				s, e := p.projection()
+				if e != nil {
+					return nil, e
+				}
+				path = append(path, s)
+			default:
+				name, e := p.name()
+				if e != nil {
+					return nil, e
+				}
+				path = append(path, child(name))
+			}
+		case p.consume("["):
+			s, e := p.bracket()
+			if e != nil {
+				return nil, e
+			}
+			if e := p.expect("]"); e != nil {
+				return nil, e
+			}
+			path = append(path, s)
+		default:
+			return path, nil
+		}
+	}
+}
+
+// bracket parses what is between [ and ].
+func (p *pathParser) bracket() (selector, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case p.consume("*"):
+		return wildcard, nil
+	case p.consume("?"):
+		if e := p.expect("("); e != nil {
+			return nil, e
+		}
+		keep, e := p.or()
+		if e != nil {
+			return nil, e
+		}
+		if e := p.expect(")"); e != nil {
+			return nil, e
+		}
+		return filter(keep), nil
+	case c == '\'' || c == '"':
+		var names []string
+		for {
+			p.skipSpace()
+			if c := p.peek(); c != '\'' && c != '"' {
+				return nil, p.errorf("expected a quoted name")
+			}
+			name, e := p.quoted()
+			if e != nil {
+				return nil, e
+			}
+			names = append(names, name)
+			p.skipSpace()
+			if !p.consume(",") {
+				return children(names), nil
+			}
+		}
+	case c == '-' || c == ':' || c >= '0' && c <= '9':
+		return p.indexes()
+	}
+	return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+}
+
+// integer parses an optional integer, reporting whether there was
+// one.
+func (p *pathParser) integer() (int, bool) {
+	p.skipSpace()
+	start := p.pos
+	p.consume("-")
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.pos++
+	}
+	n, e := strconv.Atoi(p.expr[start:p.pos])
+	if e != nil {
+		p.pos = start
+		return 0, false
+	}
+	return n, true
+}
+
+// indexes parses [N], [N,M,...] or the slice [start:end].
+func (p *pathParser) indexes() (selector, error) {
+	start, hasStart := p.integer()
+	p.skipSpace()
+	if p.consume(":") {
+		end, hasEnd := p.integer()
+		return slice(start, end, hasStart, hasEnd), nil
+	}
+	if !hasStart {
+		return nil, p.errorf("expected an index")
+	}
+	list := []int{start}
+	for p.skipSpace(); p.consume(","); p.skipSpace() {
+		n, ok := p.integer()
+		if !ok {
+			return nil, p.errorf("expected an index")
+		}
+		list = append(list, n)
+	}
+	return index(list), nil
+}
+
+// projection parses {name, key: path, ...} after the {.
+func (p *pathParser) projection() (selector, error) {
+	var keys []string
+	var paths []jsonpath
+	for {
+		p.skipSpace()
+		start := p.pos
+		path, e := p.segments(true)
+		if e != nil {
+			return nil, e
+		}
+		key := p.expr[start:p.pos]
+		if key == "" {
+			return nil, p.errorf("expected a field")
+		}
+		p.skipSpace()
+		if p.consume(":") {
+			p.skipSpace()
+			if path, e = p.segments(true); e != nil {
+				return nil, e
+			}
+		}
+		keys = append(keys, key)
+		paths = append(paths, path)
+		p.skipSpace()
+		if p.consume("}") {
+			return project(keys, paths), nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected , or }")
+		}
+	}
+}
+
+// or parses a filter, comparisons joined by && and ||.
+func (p *pathParser) or() (predicate, error) {
+	left, e := p.and()
+	if e != nil {
+		return nil, e
+	}
+	for p.skipSpace(); p.consume("||"); p.skipSpace() {
+		right, e := p.and()
+		if e != nil {
+			return nil, e
+		}
+		l := left
+		left = func(v interface{}) bool { return l(v) || right(v) }
+	}
+	return left, nil
+}
+
+func (p *pathParser) and() (predicate, error) {
+	left, e := p.comparison()
+	if e != nil {
+		return nil, e
+	}
+	for p.skipSpace(); p.consume("&&"); p.skipSpace() {
+		right, e := p.comparison()
+		if e != nil {
+			return nil, e
+		}
+		l := left
+		left = func(v interface{}) bool { return l(v) && right(v) }
+	}
+	return left, nil
+}
+
+// operators of comparisons, longest first.
+var operators = []string{"==", "!=", "<=", ">=", "=~", "<", ">"}
+
+// comparison parses "operand op operand", or an operand alone which
+// is true if it exists, possibly negated with ! or in parentheses.
+func (p *pathParser) comparison() (predicate, error) {
+	p.skipSpace()
+	if p.consume("!") {
+		inner, e := p.comparison()
+		if e != nil {
+			return nil, e
+		}
+		return func(v interface{}) bool { return !inner(v) }, nil
+	}
+	if p.consume("(") {
+		inner, e := p.or()
+		if e != nil {
+			return nil, e
+		}
+		return inner, p.expect(")")
+	}
+	left, e := p.operand()
+	if e != nil {
+		return nil, e
+	}
+	p.skipSpace()
+	op := ""
+	for _, o := range operators {
+		if p.consume(o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return func(v interface{}) bool {
+			_, ok := left(v)
+			return ok
+		}, nil
+	}
+	p.skipSpace()
+	if op == "=~" {
+		return p.match(left)
+	}
+	right, e := p.operand()
+	if e != nil {
+		return nil, e
+	}
+	return func(v interface{}) bool {
+		a, ok := left(v)
+		b, ok2 := right(v)
+		return ok && ok2 && compare(a, op, b)
+	}, nil
+}
+
+// match parses the regular expression of =~, written in quotes or
+// between slashes.
+func (p *pathParser) match(left operand) (predicate, error) {
+	var pattern string
+	var e error
+	switch p.peek() {
+	case '\'', '"':
+		pattern, e = p.quoted()
+	case '/':
+		// \/ is a slash within the expression
+		end := p.pos + 1
+		for end < len(p.expr) && p.expr[end] != '/' {
+			if p.expr[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(p.expr) {
+			return nil, p.errorf("unterminated regular expression")
+		}
+		pattern = strings.Replace(p.expr[p.pos+1:end], `\/`, "/", -1)
+		p.pos = end + 1
+	default:
+		return nil, p.errorf("expected a regular expression")
+	}
+	if e != nil {
+		return nil, e
+	}
+	re, e := regexp.Compile(pattern)
+	if e != nil {
+		return nil, p.errorf("%v", e)
+	}
+	return func(v interface{}) bool {
+		s, ok := left(v)
+		str, isString := s.(string)
+		return ok && isString && re.MatchString(str)
+	}, nil
+}
+
+// operand parses @ followed by a path, or a literal.
+func (p *pathParser) operand() (operand, error) {
+	if p.consume("@") {
+		path, e := p.segments(false)
+		if e != nil {
+			return nil, e
+		}
+		return func(v interface{}) (interface{}, bool) {
+			values := path.eval(v)
+			if len(values) == 0 {
+				return nil, false
+			}
+			return values[0], true
+		}, nil
+	}
+	var literal interface{}
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, e := p.quoted()
+		if e != nil {
+			return nil, e
+		}
+		literal = s
+	case p.consume("true"):
+		literal = true
+	case p.consume("false"):
+		literal = false
+	case p.consume("null"):
+		literal = nil
+	default:
+		start := p.pos
+		for c := p.peek(); c == '-' || c == '.' || c == 'e' || c == 'E' || c == '+' || c >= '0' && c <= '9'; c = p.peek() {
+			p.pos++
+		}
+		n := json.Number(p.expr[start:p.pos])
+		if _, e := n.Float64(); e != nil {
+			p.pos = start
+			return nil, p.errorf("expected @, a number, a string, true, false or null")
+		}
+		literal = n
+	}
+	return func(interface{}) (interface{}, bool) { return literal, true }, nil
+}
+
+// number returns v as a float64 if it is a number.
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, e := n.Float64()
+		return f, e == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// compare applies op to a and b: numbers and strings are ordered,
+// anything else can only be equal or not.
+func compare(a interface{}, op string, b interface{}) bool {
+	var order int
+	x, aNum := number(a)
+	y, bNum := number(b)
+	s, aStr := a.(string)
+	t, bStr := b.(string)
+	switch {
+	case aNum && bNum:
+		order = sign(x - y)
+	case aStr && bStr:
+		order = strings.Compare(s, t)
+	default:
+		equal := reflect.DeepEqual(a, b)
+		return op == "==" && equal || op == "!=" && !equal
+	}
+	switch op {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+func sign(f float64) int {
+	switch {
+	case f < 0:
+		return -1
+	case f > 0:
+		return 1
+	}
+	return 0
+}
+
+// items returns the elements of a list, or the values of an object
+// sorted by key.
+func items(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]interface{}, 0, len(v))
+		for _, key := range keys {
+			values = append(values, v[key])
+		}
+		return values
+	}
+	return nil
+}
+
+func child(name string) selector {
+	return children([]string{name})
+}
+
+func children(names []string) selector {
+	return func(v interface{}) []interface{} {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		var values []interface{}
+		for _, name := range names {
+			if value, ok := object[name]; ok {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+}
+
+func wildcard(v interface{}) []interface{} {
+	return items(v)
+}
+
+// descendants selects the values called name below v at any depth,
+// or all of them if name is "".
+func descendants(name string) selector {
+	var walk func(v interface{}, found []interface{}) []interface{}
+	walk = func(v interface{}, found []interface{}) []interface{} {
+		if object, ok := v.(map[string]interface{}); ok && name != "" {
+			if value, ok := object[name]; ok {
+				found = append(found, value)
+			}
+		}
+		for _, item := range items(v) {
+			if name == "" {
+				found = append(found, item)
+			}
+			found = walk(item, found)
+		}
+		return found
+	}
+	return func(v interface{}) []interface{} {
+		return walk(v, nil)
+	}
+}
+
+// index selects elements of a list, counting from the end if
+// negative.
+func index(list []int) selector {
+	return func(v interface{}) []interface{} {
+		array, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		var values []interface{}
+		for _, i := range list {
+			if i < 0 {
+				i += len(array)
+			}
+			if i >= 0 && i < len(array) {
+				values = append(values, array[i])
+			}
+		}
+		return values
+	}
+}
+
+// slice selects the elements from start up to end of a list.
+func slice(start, end int, hasStart, hasEnd bool) selector {
+	return func(v interface{}) []interface{} {
+		array, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		from, to := 0, len(array)
+		if hasStart {
+			from = clamp(start, len(array))
+		}
+		if hasEnd {
+			to = clamp(end, len(array))
+		}
+		if from >= to {
+			return nil
+		}
+		return array[from:to]
+	}
+}
+
+// clamp makes i, negative counting from the end, an index in 0..n.
+func clamp(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// filter selects the items of v that keep accepts.
+func filter(keep predicate) selector {
+	return func(v interface{}) []interface{} {
+		var values []interface{}
+		for _, item := range items(v) {
+			if keep(item) {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+}
+
+// projected is an object with its keys in the order they were asked
+// for.
+type projected struct {
+	keys   []string
+	values []interface{}
+}
+
+func (p projected) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, key := range p.keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		k, _ := json.Marshal(key)
+		v, e := json.Marshal(p.values[i])
+		if e != nil {
+			return nil, e
+		}
+		b.Write(k)
+		b.WriteString(":")
+		b.Write(v)
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+// project selects an object of the first value of each path in v,
+// null where a path selects nothing.
+func project(keys []string, paths []jsonpath) selector {
+	return func(v interface{}) []interface{} {
+		p := projected{keys: keys}
+		for _, path := range paths {
+			var value interface{}
+			if values := path.eval(v); len(values) > 0 {
+				value = values[0]
+			}
+			p.values = append(p.values, value)
+		}
+		return []interface{}{p}
+	}
+}
@@ -0,0 +1,90 @@
+package marathon
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+const pathDocument = `{
+	"apps": [
+		{"id": "/web/a", "cmd": "run a", "instances": 2, "labels": {"team": "web"},
+		 "tasks": [{"host": "h1"}, {"host": "h2"}]},
+		{"id": "/web/b", "cmd": "run b", "instances": 0, "labels": {"team": "web"}},
+		{"id": "/db/c", "cmd": "run/c", "instances": 10, "labels": {"team": "db"},
+		 "tasks": [{"host": "h3"}]}
+	]
+}`
+
+func evalPath(t *testing.T, expr string) string {
+	path, e := parseJSONPath(expr)
+	if e != nil {
+		t.Fatalf("%s: %v", expr, e)
+	}
+	var root interface{}
+	dec := json.NewDecoder(bytes.NewReader([]byte(pathDocument)))
+	dec.UseNumber()
+	if e := dec.Decode(&root); e != nil {
+		t.Fatal(e)
+	}
+	b, e := json.Marshal(path.eval(root))
+	if e != nil {
+		t.Fatalf("%s: %v", expr, e)
+	}
+	return string(b)
+}
+
+func TestJSONPath(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`$.apps[*].id`, `["/web/a","/web/b","/db/c"]`},
+		{`.apps[*].id`, `["/web/a","/web/b","/db/c"]`},
+		{`apps[*].id`, `["/web/a","/web/b","/db/c"]`},
+		{`$.apps[0].id`, `["/web/a"]`},
+		{`$.apps[-1].id`, `["/db/c"]`},
+		{`$.apps[0,2].id`, `["/web/a","/db/c"]`},
+		{`$.apps[0:2].id`, `["/web/a","/web/b"]`},
+		{`$.apps[1:].id`, `["/web/b","/db/c"]`},
+		{`$.apps[:-2].id`, `["/web/a"]`},
+		{`$.apps[5].id`, `null`},
+		{`$.apps[0]['id','cmd']`, `["/web/a","run a"]`},
+		{`$.apps[0]["labels"].team`, `["web"]`},
+		{`..host`, `["h1","h2","h3"]`},
+		{`$.apps[?(@.instances > 0)].id`, `["/web/a","/db/c"]`},
+		{`$.apps[?(@.instances >= 2 && @.labels.team == 'web')].id`, `["/web/a"]`},
+		{`$.apps[?(@.instances == 0 || @.labels.team == "db")].id`, `["/web/b","/db/c"]`},
+		{`$.apps[?(!(@.instances > 0))].id`, `["/web/b"]`},
+		{`$.apps[?(@.tasks)].id`, `["/web/a","/db/c"]`},
+		{`$.apps[?(@.id =~ /^\/web/)].id`, `["/web/a","/web/b"]`},
+		{`$.apps[?(@.cmd =~ 'run/')].id`, `["/db/c"]`},
+		{`$.apps[?(@.instances < 1e1)].id`, `["/web/a","/web/b"]`},
+		{`$.apps[0].{id, n: instances, team: labels.team}`, `[{"id":"/web/a","n":2,"team":"web"}]`},
+		{`$.apps[1].{id, tasks}`, `[{"id":"/web/b","tasks":null}]`},
+		{`$.apps[0].labels.*`, `["web"]`},
+	}
+	for _, test := range tests {
+		if got := evalPath(t, test.expr); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		`$.apps[`,
+		`$.apps[*`,
+		`$.apps[?(@.id == )]`,
+		`$.apps[?(@.id =~ /web)]`,
+		`$.apps[?(@.id =~ '(')]`,
+		`$.apps['id`,
+		`$.apps.{id,`,
+		`$.apps]`,
+		`$.`,
+	} {
+		if _, e := parseJSONPath(expr); e == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
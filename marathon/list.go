@@ -0,0 +1,304 @@
+package marathon
+
+// Flags of the list actions choosing the items they show:
+//
+//	--sort FIELD[:desc]     order by FIELD, descending with :desc
+//	--filter FIELD=VALUE    keep the items where FIELD is VALUE
+//	--filter FIELD~REGEX    keep the items where FIELD matches REGEX
+//	--limit N               keep the first N items
+//
+// FIELD is the name of a field of the items in the JSON response, like
+// instances or labels.team, in any case. The items are chosen before
+// formatting, so every format shows the same ones.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// listFlags are the values of the flags of a list action.
+type listFlags struct {
+	sort    string
+	filters filterFlags
+	limit   int
+}
+
+// filterFlags collects every --filter given.
+type filterFlags []string
+
+func (f *filterFlags) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *filterFlags) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// listFlagSet returns the flags of the list action called name.
+func listFlagSet(name string, l *listFlags) *flag.FlagSet {
+	fs := newFlagSet(name)
+	fs.StringVar(&l.sort, "sort", "", "order by `FIELD`, or FIELD:desc for descending")
+	fs.Var(&l.filters, "filter", "keep items where `FIELD=VALUE`, or FIELD~REGEX, may be repeated")
+	fs.IntVar(&l.limit, "limit", 0, "keep the first `N` items")
+	return fs
+}
+
+// selection is the compiled form of listFlags.
+type selection struct {
+	keep  []func(item interface{}) bool
+	field []string // to sort by, if any
+	desc  bool
+	limit int
+}
+
+// listFlagsOf parses the list flags in args, exiting on a bad flag,
+// and returns the other arguments.
+func listFlagsOf(name string, args []string) ([]string, selection) {
+	var l listFlags
+	args = flagsOf(listFlagSet(name, &l), args)
+	s, e := l.selection()
+	check.Check(e == nil, name+":", e)
+	return args, s
+}
+
+func (l listFlags) selection() (selection, error) {
+	var s selection
+	for _, filter := range l.filters {
+		keep, e := compileFilter(filter)
+		if e != nil {
+			return selection{}, e
+		}
+		s.keep = append(s.keep, keep)
+	}
+	if l.sort != "" {
+		field := l.sort
+		if i := strings.LastIndex(field, ":"); i >= 0 {
+			switch field[i+1:] {
+			case "desc":
+				s.desc = true
+			case "asc":
+			default:
+				return selection{}, fmt.Errorf("sort %s: expected FIELD, FIELD:asc or FIELD:desc", l.sort)
+			}
+			field = field[:i]
+		}
+		if field == "" {
+			return selection{}, fmt.Errorf("sort %s: missing field", l.sort)
+		}
+		s.field = strings.Split(field, ".")
+	}
+	if l.limit < 0 {
+		return selection{}, errors.New("limit must not be negative")
+	}
+	s.limit = l.limit
+	return s, nil
+}
+
+// compileFilter parses FIELD=VALUE or FIELD~REGEX. A field holding a
+// list matches if any of its values does.
+func compileFilter(filter string) (func(item interface{}) bool, error) {
+	i := strings.IndexAny(filter, "=~")
+	if i <= 0 {
+		return nil, fmt.Errorf("filter %s: expected FIELD=VALUE or FIELD~REGEX", filter)
+	}
+	field, want := strings.Split(filter[:i], "."), filter[i+1:]
+	matches := func(s string) bool { return s == want }
+	if filter[i] == '~' {
+		re, e := regexp.Compile(want)
+		if e != nil {
+			return nil, fmt.Errorf("filter %s: %v", filter, e)
+		}
+		matches = re.MatchString
+	}
+	return func(item interface{}) bool {
+		v, _ := fieldOf(item, field)
+		if list, ok := v.([]interface{}); ok {
+			for _, v := range list {
+				if matches(text(v)) {
+					return true
+				}
+			}
+			return false
+		}
+		return matches(text(v))
+	}, nil
+}
+
+// empty reports whether s keeps every item in order.
+func (s selection) empty() bool {
+	return len(s.keep) == 0 && s.field == nil && s.limit == 0
+}
+
+// apply returns the items s keeps, sorted and limited.
+func (s selection) apply(items []interface{}) []interface{} {
+	kept := []interface{}{}
+	for _, item := range items {
+		if s.keeps(item) {
+			kept = append(kept, item)
+		}
+	}
+	if s.field != nil {
+		sort.SliceStable(kept, func(i, j int) bool {
+			a, aok := fieldOf(kept[i], s.field)
+			b, bok := fieldOf(kept[j], s.field)
+			if aok != bok {
+				// items without the field go last
+				return aok
+			}
+			if s.desc {
+				return less(b, a)
+			}
+			return less(a, b)
+		})
+	}
+	if s.limit > 0 && len(kept) > s.limit {
+		kept = kept[:s.limit]
+	}
+	return kept
+}
+
+func (s selection) keeps(item interface{}) bool {
+	for _, keep := range s.keep {
+		if !keep(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// choose returns raw with the items of the list found at path chosen
+// by s, and the result decoded into a new value of the type of typed
+// so templates see the same items. raw and typed are returned as they
+// are if s is empty.
+func (s selection) choose(raw []byte, typed interface{}, path ...string) ([]byte, interface{}) {
+	if s.empty() {
+		return raw, typed
+	}
+	root := decodeNumbers(raw)
+	if len(path) == 0 {
+		list, _ := root.([]interface{})
+		root = s.apply(list)
+	} else {
+		parent := root
+		for _, key := range path[:len(path)-1] {
+			parent, _ = fieldOf(parent, []string{key})
+		}
+		object, ok := parent.(map[string]interface{})
+		check.Check(ok, "unexpected response, no", strings.Join(path, "."))
+		list, _ := object[path[len(path)-1]].([]interface{})
+		object[path[len(path)-1]] = s.apply(list)
+	}
+	return encodeChosen(root, typed)
+}
+
+// chooseTree is choose for trees like groups, where the items are the
+// root and every object under its key, depth first. The items chosen
+// are returned as a list, each with the objects under it.
+func (s selection) chooseTree(raw []byte, typed interface{}, key string) ([]byte, interface{}) {
+	var items []interface{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		items = append(items, v)
+		children, _ := fieldOf(v, []string{key})
+		list, _ := children.([]interface{})
+		for _, child := range list {
+			walk(child)
+		}
+	}
+	walk(decodeNumbers(raw))
+	return encodeChosen(s.apply(items), typed)
+}
+
+func decodeNumbers(raw []byte) interface{} {
+	var root interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	e := dec.Decode(&root)
+	check.Check(e == nil, "failed to decode response", e)
+	return root
+}
+
+// encodeChosen returns v as JSON, and decoded into a new value of
+// the type of typed, sharing nothing with the values left out.
+func encodeChosen(v interface{}, typed interface{}) ([]byte, interface{}) {
+	b, e := json.Marshal(v)
+	check.Check(e == nil, "failed to encode response", e)
+	fresh := reflect.New(reflect.TypeOf(typed))
+	e = json.Unmarshal(b, fresh.Interface())
+	check.Check(e == nil, "failed to decode response", e)
+	return b, fresh.Elem().Interface()
+}
+
+// fieldOf returns the value at the path of field names in v, matching
+// names in any case, and whether there is one.
+func fieldOf(v interface{}, field []string) (interface{}, bool) {
+	for _, name := range field {
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok = object[name]
+		if !ok {
+			for key, value := range object {
+				if strings.EqualFold(key, name) {
+					v, ok = value, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// text is the form of v that filters match and sorting compares:
+// strings and numbers as they are, lists as their values joined with
+// commas and objects as JSON.
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			values[i] = text(item)
+		}
+		return strings.Join(values, ",")
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// less orders numbers by value and anything else by text.
+func less(a, b interface{}) bool {
+	x, xok := a.(json.Number)
+	y, yok := b.(json.Number)
+	if xok && yok {
+		f, e1 := x.Float64()
+		g, e2 := y.Float64()
+		if e1 == nil && e2 == nil {
+			return f < g
+		}
+	}
+	return text(a) < text(b)
+}
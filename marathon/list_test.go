@@ -0,0 +1,105 @@
+package marathon
+
+import (
+	"reflect"
+	"testing"
+)
+
+const listDocument = `{"apps": [
+	{"id": "/a", "cmd": "run a", "instances": 10, "labels": {"team": "web"}},
+	{"id": "/b", "instances": 2, "labels": {"team": "db"}},
+	{"id": "/c", "cmd": "run c", "instances": 1, "labels": {"team": "web"}},
+	{"id": "/d", "labels": {"team": "web"}}
+]}`
+
+func chosenIDs(t *testing.T, l listFlags) []string {
+	s, e := l.selection()
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, typed := s.choose([]byte(listDocument), Applications{}, "apps")
+	ids := []string{}
+	for _, app := range typed.(Applications).Apps {
+		ids = append(ids, app.ID)
+	}
+	return ids
+}
+
+func TestSelection(t *testing.T) {
+	tests := []struct {
+		flags listFlags
+		want  []string
+	}{
+		{listFlags{limit: 4}, []string{"/a", "/b", "/c", "/d"}},
+		{listFlags{filters: filterFlags{"labels.team=web"}}, []string{"/a", "/c", "/d"}},
+		{listFlags{filters: filterFlags{"LABELS.TEAM=web", "cmd~^run"}}, []string{"/a", "/c"}},
+		{listFlags{filters: filterFlags{"id=/x"}}, []string{}},
+		// numbers are compared by value, items without the field go last
+		{listFlags{sort: "instances"}, []string{"/c", "/b", "/a", "/d"}},
+		{listFlags{sort: "instances:desc"}, []string{"/a", "/b", "/c", "/d"}},
+		{listFlags{sort: "labels.team:asc"}, []string{"/b", "/a", "/c", "/d"}},
+		{listFlags{sort: "id:desc", limit: 2}, []string{"/d", "/c"}},
+		{listFlags{filters: filterFlags{"labels.team=web"}, limit: 5}, []string{"/a", "/c", "/d"}},
+	}
+	for _, test := range tests {
+		if got := chosenIDs(t, test.flags); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: got %v, want %v", test.flags, got, test.want)
+		}
+	}
+}
+
+func TestSelectionErrors(t *testing.T) {
+	for _, l := range []listFlags{
+		{sort: "id:up"},
+		{sort: ":desc"},
+		{filters: filterFlags{"id"}},
+		{filters: filterFlags{"=x"}},
+		{filters: filterFlags{"id~("}},
+		{limit: -1},
+	} {
+		if _, e := l.selection(); e == nil {
+			t.Errorf("%+v: expected an error", l)
+		}
+	}
+}
+
+func TestChooseDecodesFreshValues(t *testing.T) {
+	s, e := listFlags{filters: filterFlags{"id=/b"}}.selection()
+	if e != nil {
+		t.Fatal(e)
+	}
+	// the value decoded from the whole response, which templates used
+	// to be given with the chosen items decoded over it
+	stale := Applications{Apps: []Application{{ID: "/a", Cmd: "run a"}}}
+	raw, typed := s.choose([]byte(listDocument), stale, "apps")
+	apps := typed.(Applications).Apps
+	if len(apps) != 1 || apps[0].ID != "/b" || apps[0].Cmd != "" {
+		t.Errorf("got %+v, want just /b without a cmd", apps)
+	}
+	if stale.Apps[0].ID != "/a" {
+		t.Errorf("the value given was changed to %+v", stale.Apps[0])
+	}
+	want := `{"apps":[{"id":"/b","instances":2,"labels":{"team":"db"}}]}`
+	if string(raw) != want {
+		t.Errorf("got %s, want %s", raw, want)
+	}
+}
+
+func TestChooseTree(t *testing.T) {
+	raw := []byte(`{"id": "/", "groups": [
+		{"id": "/web", "groups": [{"id": "/web/api", "groups": []}]},
+		{"id": "/db", "groups": []}
+	]}`)
+	s, e := listFlags{filters: filterFlags{"id~^/web"}}.selection()
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, typed := s.chooseTree(raw, []*Group(nil), "groups")
+	var ids []string
+	for _, group := range typed.([]*Group) {
+		ids = append(ids, group.GroupID)
+	}
+	if want := []string{"/web", "/web/api"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+}
@@ -4,58 +4,67 @@ package marathon
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/http"
-	"strings"
-	"time"
 
 	"github.com/layneYoo/mCtl/check"
 )
 
-// ping (todo ping all hosts)
+// ping
 type MarathonPing struct {
 	Clients *Client
 	Formats Formatter
 }
 
-func (p MarathonPing) Apply(args []string) {
-	hosts := p.Clients.login.Hosts
-	timings := make(map[string]time.Duration)
-	for _, host := range hosts {
-		request, e := http.NewRequest("/ping", host, nil)
-		check.Check(e == nil, "could not create ping request")
-		p.Clients.tweak(request)
-		start := time.Now()
-		_, err := p.Clients.client.Do(request)
-		var elapsed time.Duration
-		if err == nil {
-			elapsed = time.Now().Sub(start)
-		}
-		timings[host] = elapsed
-	}
+// pingResult is the time host took to answer a ping, or why it did
+// not.
+type pingResult struct {
+	Host     string `json:"host"`
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
 
-	var b bytes.Buffer
-	for host, duration := range timings {
-		b.WriteString(host)
-		b.WriteString(" ")
-		if duration == 0 {
-			b.WriteString("-")
+func (p MarathonPing) Apply(ctx context.Context, args []string) {
+	results := []pingResult{}
+	for _, host := range p.Clients.login.Hosts {
+		result := pingResult{Host: host}
+		if elapsed, e := p.Clients.Ping(ctx, host); e != nil {
+			result.Error = e.Error()
 		} else {
-			b.WriteString(duration.String())
+			result.Duration = elapsed.String()
 		}
-		b.WriteString("\n")
+		results = append(results, result)
 	}
-	fmt.Println(p.Formats.Format(strings.NewReader(b.String()), p.Humanize))
+	p.Formats.Check(ctx.Err(), "ping interrupted")
+	b, e := json.Marshal(results)
+	check.Check(e == nil, "failed to encode ping results", e)
+	fmt.Println(p.Formats.For(results).FormatTable(bytes.NewReader(b), p.Tabulate))
 }
 
-func (P MarathonPing) Humanize(body io.Reader) string {
-	b, e := ioutil.ReadAll(body)
-	check.Check(e == nil, "reading ping response failed", e)
-	text := "HOST DURATION\n" + string(b)
-	return Columnize(text)
+func (p MarathonPing) Usage() []Usage {
+	return []Usage{
+		{"", "ping each Marathon host"},
+	}
+}
+
+func (p MarathonPing) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var results []pingResult
+	e := dec.Decode(&results)
+	check.Check(e == nil, "failed to decode ping results", e)
+	t := newTable("HOST", "DURATION")
+	t.wide("ERROR")
+	for _, result := range results {
+		// a failed ping shows - for its duration, and its error if wide
+		duration := result.Duration
+		if result.Error != "" {
+			duration = "-"
+		}
+		t.add(result.Host, duration, result.Error)
+	}
+	return t
 }
 
 // leader
@@ -64,23 +73,27 @@ type MarathonLeader struct {
 	Formats Formatter
 }
 
-func (l MarathonLeader) Apply(args []string) {
-	request := l.Clients.GET("/v2/leader")
-	response, e := l.Clients.Do(request)
-	check.Check(e == nil, "get leader failed", e)
-	c := response.StatusCode
-	check.Check(c == 200, "get leader bad status", c)
-	defer response.Body.Close()
-	fmt.Println(l.Formats.Format(response.Body, l.Humanize))
+func (l MarathonLeader) Apply(ctx context.Context, args []string) {
+	var raw []byte
+	which, e := l.Clients.GetLeader(withRaw(ctx, &raw))
+	l.Formats.Check(e, "get leader failed")
+	fmt.Println(l.Formats.For(which).FormatTable(bytes.NewReader(raw), l.Tabulate))
+}
+
+func (l MarathonLeader) Usage() []Usage {
+	return []Usage{
+		{"", "get the current Marathon leader"},
+	}
 }
 
-func (l MarathonLeader) Humanize(body io.Reader) string {
+func (l MarathonLeader) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var which Which
 	e := dec.Decode(&which)
 	check.Check(e == nil, "failed to decode response", e)
-	text := "LEADER\n" + which.Leader
-	return Columnize(text)
+	t := newTable("LEADER")
+	t.add(which.Leader)
+	return t
 }
 
 // abdicate
@@ -89,20 +102,25 @@ type MarathonAbdicate struct {
 	Formats Formatter
 }
 
-func (a MarathonAbdicate) Apply(args []string) {
-	request := a.Clients.DELETE("/v2/leader")
-	response, e := a.Clients.Do(request)
-	check.Check(e == nil, "abdicate request failed", e)
-	c := response.StatusCode
-	check.Check(c == 200, "abdicate bad status", c)
-	defer response.Body.Close()
-	fmt.Println(a.Formats.Format(response.Body, a.Humanize))
+func (a MarathonAbdicate) Apply(ctx context.Context, args []string) {
+	var raw []byte
+	message, e := a.Clients.Abdicate(withRaw(ctx, &raw))
+	a.Formats.Check(e, "abdicate request failed")
+	fmt.Println(a.Formats.For(message).FormatTable(bytes.NewReader(raw), a.Tabulate))
+}
+
+func (a MarathonAbdicate) Usage() []Usage {
+	return []Usage{
+		{"", "force the current leader to relinquish control"},
+	}
 }
 
-func (a MarathonAbdicate) Humanize(body io.Reader) string {
+func (a MarathonAbdicate) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var mess Message
 	e := dec.Decode(&mess)
 	check.Check(e == nil, "failed to decode response", e)
-	return "MESSAGE\n" + mess.Message
+	t := newTable("MESSAGE")
+	t.add(mess.Message)
+	return t
 }
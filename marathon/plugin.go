@@ -0,0 +1,164 @@
+package marathon
+
+// External commands: marathonctl <name> runs marathonctl-<name> from
+// PATH, and plugin list shows which ones there are
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// pluginPrefix starts the name of every plugin executable.
+const pluginPrefix = "marathonctl-"
+
+// plugin is an executable found on PATH.
+type plugin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// findPlugin returns the path of the plugin called name, if any.
+func findPlugin(name string) (string, bool) {
+	if name == "" || strings.ContainsRune(name, filepath.Separator) {
+		return "", false
+	}
+	path, e := exec.LookPath(pluginPrefix + name)
+	return path, e == nil
+}
+
+// plugins returns the plugins on PATH sorted by name, the first one
+// found for each name, as run by marathonctl <name>.
+func plugins() []plugin {
+	found := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		entries, e := ioutil.ReadDir(dir)
+		if e != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := strings.TrimPrefix(entry.Name(), pluginPrefix)
+			if name == entry.Name() || name == "" || found[name] != "" {
+				continue
+			}
+			if entry.Mode().IsRegular() && entry.Mode()&0111 != 0 {
+				found[name] = filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+	list := []plugin{}
+	for name, path := range found {
+		list = append(list, plugin{name, path})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Plugin runs the plugin executable at Path. The settings of
+// marathonctl are passed in the MARATHON_* environment variables it
+// reads itself, with the password resolved from its sources, so that
+// a plugin can call marathonctl or Marathon as configured.
+type Plugin struct {
+	Path     string
+	Settings Settings
+	Login    *Login
+}
+
+func (p Plugin) Select(ctx context.Context, args []string) {
+	env, e := p.environment()
+	check.Check(e == nil, "failed to get password for plugin", e)
+	cmd := exec.CommandContext(ctx, p.Path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), env...)
+	e = cmd.Run()
+	if ctx.Err() != nil {
+		os.Exit(ExitCode(ctx.Err()))
+	}
+	var exit *exec.ExitError
+	if errors.As(e, &exit) {
+		os.Exit(exit.ExitCode())
+	}
+	check.Check(e == nil, "failed to run plugin", e)
+}
+
+// environment returns the settings which are not left at their
+// defaults as NAME=value.
+func (p Plugin) environment() ([]string, error) {
+	layers := p.Settings.layers(p.Settings.Context)
+	var env []string
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		value, source := resolve(layers, s.key)
+		if source == "default" {
+			value = ""
+		}
+		switch s.key {
+		case "marathon.host":
+			value = p.Settings.Host
+		case "marathon.format":
+			value = p.Settings.Format
+		case "marathon.context":
+			value = p.Settings.Context
+		case "marathon.password":
+			if p.Login.User == "" {
+				continue
+			}
+			pass, e := p.Login.Password()
+			if e != nil {
+				return nil, e
+			}
+			value = pass
+		}
+		if value != "" {
+			env = append(env, s.env+"="+value)
+		}
+	}
+	return env, nil
+}
+
+type PluginList struct {
+	Formats Formatter
+}
+
+func (p PluginList) Apply(ctx context.Context, args []string) {
+	check.Check(len(args) == 0, "no arguments")
+	b, e := json.Marshal(plugins())
+	check.Check(e == nil, "failed to encode plugins", e)
+	fmt.Println(p.Formats.FormatTable(bytes.NewReader(b), p.Tabulate))
+}
+
+func (p PluginList) Usage() []Usage {
+	return []Usage{
+		{"", "list the " + pluginPrefix + "<name> commands found on PATH"},
+	}
+}
+
+func (p PluginList) Tabulate(body io.Reader) Table {
+	dec := json.NewDecoder(body)
+	var list []plugin
+	e := dec.Decode(&list)
+	check.Check(e == nil, "failed to decode plugins", e)
+	t := newTable("NAME", "PATH")
+	for _, plugin := range list {
+		t.add(plugin.Name, plugin.Path)
+	}
+	return t
+}
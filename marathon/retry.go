@@ -0,0 +1,117 @@
+package marathon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Options control how the Client talks to Marathon.
+type Options struct {
+	ConnectTimeout time.Duration // time allowed to establish a connection
+	RequestTimeout time.Duration // time allowed for a whole request, 0 is no limit
+	Retries        int           // extra rounds tried after a retryable failure
+	Backoff        time.Duration // wait before the first retry, doubled each time
+	TLS            TLS
+	Auth           AuthOptions
+	Trace          bool // log every request sent on stderr
+	TraceCurl      bool // with Trace, also log an equivalent curl command
+}
+
+// DefaultOptions are used for any value not set by flags or the
+// config file.
+var DefaultOptions = Options{
+	ConnectTimeout: 5 * time.Second,
+	RequestTimeout: 60 * time.Second,
+	Retries:        2,
+	Backoff:        500 * time.Millisecond,
+}
+
+const maxBackoff = 30 * time.Second
+
+func (o Options) httpClient() (http.Client, error) {
+	tlsConfig, e := o.TLS.config()
+	if e != nil {
+		return http.Client{}, e
+	}
+	dialer := &net.Dialer{
+		Timeout:   o.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return http.Client{
+		Timeout: o.RequestTimeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: o.ConnectTimeout,
+			TLSClientConfig:     tlsConfig,
+		},
+	}, nil
+}
+
+// backoff returns how long to wait before retry n (starting at 1),
+// doubling each time with up to half of the wait replaced by jitter.
+func (o Options) backoff(n int) time.Duration {
+	d := o.Backoff
+	for i := 1; i < n && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryableStatus reports whether status means the host could not
+// serve the request right now, as happens during a leader election.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryableError reports whether e is a connection that was dropped
+// by the other end.
+func retryableError(e error) bool {
+	return errors.Is(e, syscall.ECONNRESET) ||
+		errors.Is(e, syscall.ECONNREFUSED) ||
+		errors.Is(e, io.ErrUnexpectedEOF) ||
+		errors.Is(e, io.EOF)
+}
+
+// logAttempt writes one line to stderr describing a failed attempt,
+// host may be empty if no host could be chosen.
+func logAttempt(r *http.Request, host string, attempt, tries int, response *http.Response, e error) {
+	outcome := ""
+	if e != nil {
+		outcome = describe(e)
+	} else {
+		outcome = response.Status
+	}
+	fmt.Fprintf(os.Stderr, "attempt %d/%d %s %s%s: %s\n", attempt, tries, r.Method, host, trimHost(r), outcome)
+}
@@ -0,0 +1,236 @@
+package marathon
+
+// Tables of responses, printed as columns by the human and wide
+// formats and as rows of values by csv and tsv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// Column is one column of a Table. Wide columns are only shown by
+// the wide, csv and tsv formats, or when chosen by -columns.
+type Column struct {
+	Name string
+	Wide bool
+}
+
+// Table holds the rows of a response, one value per column, so values
+// may be empty or hold spaces.
+type Table struct {
+	Columns []Column
+	Rows    [][]string
+}
+
+// Tabulate turns a response into a Table.
+type Tabulate func(input io.Reader) Table
+
+// TableOptions choose what tables show.
+type TableOptions struct {
+	Columns   []string // names of the columns to show, in order, all if empty
+	NoHeaders bool     // leave out the row of names
+}
+
+// minWidth is the width below which columns are not truncated to fit
+// the terminal.
+const minWidth = 6
+
+// newTable returns an empty Table with columns named names.
+func newTable(names ...string) Table {
+	var t Table
+	for _, name := range names {
+		t.Columns = append(t.Columns, Column{name, false})
+	}
+	return t
+}
+
+// wide adds wide columns named names.
+func (t *Table) wide(names ...string) {
+	for _, name := range names {
+		t.Columns = append(t.Columns, Column{name, true})
+	}
+}
+
+// add appends a row of values, one for each column.
+func (t *Table) add(values ...string) {
+	t.Rows = append(t.Rows, values)
+}
+
+// String lines up the columns of t which are not wide.
+func (t Table) String() string {
+	return columnize(t.lines(t.shown(TableOptions{}, false), true), 0)
+}
+
+// shown returns the indexes of the columns options choose, or of the
+// columns which are not wide if none are chosen and wide is false.
+func (t Table) shown(options TableOptions, wide bool) []int {
+	var shown []int
+	if len(options.Columns) == 0 {
+		for i, column := range t.Columns {
+			if wide || !column.Wide {
+				shown = append(shown, i)
+			}
+		}
+		return shown
+	}
+	var names []string
+	for _, column := range t.Columns {
+		names = append(names, strings.ToLower(column.Name))
+	}
+	for _, name := range options.Columns {
+		i := columnIndex(names, strings.ToLower(name))
+		check.Check(i >= 0, "unknown column", name+", choose from", strings.Join(names, ","))
+		shown = append(shown, i)
+	}
+	return shown
+}
+
+func columnIndex(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// lines returns the rows of t with just the columns shown, after the
+// names of the columns if headers is set.
+func (t Table) lines(shown []int, headers bool) [][]string {
+	var lines [][]string
+	if headers {
+		var names []string
+		for _, i := range shown {
+			names = append(names, t.Columns[i].Name)
+		}
+		lines = append(lines, names)
+	}
+	for _, row := range t.Rows {
+		line := make([]string, len(shown))
+		for j, i := range shown {
+			if i < len(row) {
+				line[j] = row[i]
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// columnize lines up the columns of lines two spaces apart. If width is
+// not zero the widest columns are narrowed until lines fit in width
+// characters, cutting the values which are too long.
+func columnize(lines [][]string, width int) string {
+	var widths []int
+	for _, line := range lines {
+		for i, value := range line {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(value); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	if width > 0 {
+		narrow(widths, width)
+	}
+	var b bytes.Buffer
+	for _, line := range lines {
+		var cells []string
+		for i, value := range line {
+			value = truncate(value, widths[i])
+			cells = append(cells, value+strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value)))
+		}
+		b.WriteString(strings.TrimRight(strings.Join(cells, "  "), " "))
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// narrow takes one character at a time from the widest of widths
+// until they fit in width, leaving none narrower than minWidth.
+func narrow(widths []int, width int) {
+	total := 2 * (len(widths) - 1)
+	for _, w := range widths {
+		total += w
+	}
+	for total > width {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minWidth {
+			return
+		}
+		widths[widest]--
+		total--
+	}
+}
+
+// truncate cuts value to width characters, ending it with … if it
+// was longer.
+func truncate(value string, width int) string {
+	if utf8.RuneCountInString(value) <= width {
+		return value
+	}
+	runes := []rune(value)
+	return string(runes[:width-1]) + "…"
+}
+
+// terminalWidth returns the width human tables are truncated to: that
+// of $COLUMNS if it is set, else that of the terminal on stdout, or 0
+// if stdout is not a terminal.
+func terminalWidth() int {
+	if n, e := strconv.Atoi(os.Getenv("COLUMNS")); e == nil && n > 0 {
+		return n
+	}
+	return stdoutWidth()
+}
+
+// delimited writes lines as CSV with values separated by comma,
+// quoting those that need it.
+func delimited(lines [][]string, comma rune) string {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Comma = comma
+	w.WriteAll(lines)
+	check.Check(w.Error() == nil, "failed to write", w.Error())
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// FormatTable is Format for responses shown as tables, which can also
+// be printed as wide, csv and tsv.
+func (f Formatter) FormatTable(input io.Reader, tabulate Tabulate) string {
+	render := func(input io.Reader) string {
+		t := tabulate(input)
+		lines := t.lines(t.shown(f.table, f.format != Human), !f.table.NoHeaders)
+		switch f.format {
+		case Csv:
+			return delimited(lines, ',')
+		case Tsv:
+			return delimited(lines, '\t')
+		}
+		return columnize(lines, terminalWidth())
+	}
+	switch f.format {
+	case Wide, Csv, Tsv:
+		return render(input)
+	}
+	return f.Format(input, render)
+}
+
+// WithTable returns a Formatter which shows tables as options choose.
+func (f Formatter) WithTable(options TableOptions) Formatter {
+	f.table = options
+	return f
+}
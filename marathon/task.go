@@ -4,11 +4,13 @@ package marathon
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/layneYoo/mCtl/check"
 )
@@ -18,76 +20,81 @@ type TaskList struct {
 	Formats Formatter
 }
 
-func (t TaskList) Apply(args []string) {
+func (t TaskList) Flags() *flag.FlagSet {
+	return listFlagSet("task list", &listFlags{})
+}
+
+func (t TaskList) Apply(ctx context.Context, args []string) {
+	args, chosen := listFlagsOf("task list", args)
 	switch len(args) {
 	case 0:
-		t.listAll()
+		t.listAll(ctx, chosen)
 	case 1:
-		t.listById(args[0])
+		t.listById(ctx, args[0], chosen)
 	default:
 		check.Check(false, "too many arguments")
 	}
 }
 
-func (t TaskList) listAll() {
-	path := "/v2/tasks"
-	request := t.Clients.GET(path)
-	response, e := t.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-	fmt.Println(t.Formats.Format(response.Body, t.HumanizeAll))
+func (t TaskList) Usage() []Usage {
+	return []Usage{
+		{"", "list all tasks"},
+		{"[id]", "list tasks of app id"},
+	}
+}
+
+func (t TaskList) Complete(ctx context.Context, args []string) []string {
+	if len(args) == 0 {
+		return t.Clients.appIDs(ctx)
+	}
+	return nil
+}
+
+func (t TaskList) listAll(ctx context.Context, chosen selection) {
+	var raw []byte
+	tasks, e := t.Clients.ListTasks(withRaw(ctx, &raw))
+	t.Formats.Check(e, "failed to list tasks")
+	raw, chosenTasks := chosen.choose(raw, tasks, "tasks")
+	fmt.Println(t.Formats.For(chosenTasks).FormatTable(bytes.NewReader(raw), t.TabulateAll))
 }
 
-func (t TaskList) HumanizeAll(body io.Reader) string {
+func (t TaskList) TabulateAll(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var tasks Tasks
 	e := dec.Decode(&tasks)
 	check.Check(e == nil, "failed to unmarshal response", e)
-	var b bytes.Buffer
+	table := newTable("APPID", "HOST", "VERSION", "TASKID")
+	table.wide("PORTS", "STARTED")
 	for _, task := range tasks.Tasks {
-		b.WriteString(task.AppID)
-		b.WriteString(" ")
-		b.WriteString(task.Host)
-		b.WriteString(" ")
-		b.WriteString(task.Version)
-		b.WriteString(" ")
-		b.WriteString(task.ID)
-		b.WriteString("\n")
+		table.add(task.AppID, task.Host, task.Version, task.ID, joinInts(task.Ports), task.StartedAt)
 	}
-	title := "APPID HOST VERSION TASKID\n"
-	text := title + b.String()
-	return Columnize(text)
+	return table
 }
 
-func (t TaskList) listById(id string) {
-	esc := url.QueryEscape(id)
-	path := "/v2/apps/" + esc + "?embed=apps.tasks"
-	request := t.Clients.GET(path)
-	response, e := t.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-	fmt.Println(t.Formats.Format(response.Body, t.HumanizeById))
+func (t TaskList) listById(ctx context.Context, id string, chosen selection) {
+	var raw []byte
+	tasks, e := t.Clients.ListAppTasks(withRaw(ctx, &raw), id)
+	t.Formats.Check(e, "failed to list tasks")
+	if !chosen.empty() {
+		var appbyid interface{}
+		raw, appbyid = chosen.choose(raw, AppById{}, "app", "tasks")
+		tasks = appbyid.(AppById).App.Tasks
+	}
+	fmt.Println(t.Formats.For(tasks).FormatTable(bytes.NewReader(raw), t.TabulateById))
 }
 
-func (t TaskList) HumanizeById(body io.Reader) string {
+func (t TaskList) TabulateById(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var appbyid AppById
 	e := dec.Decode(&appbyid)
 	check.Check(e == nil, "failed to unmarshal response", e)
 
-	var b bytes.Buffer
+	table := newTable("ID", "HOST", "VERSION")
+	table.wide("PORTS", "STARTED")
 	for _, task := range appbyid.App.Tasks {
-		b.WriteString(task.ID)
-		b.WriteString(" ")
-		b.WriteString(task.Host)
-		b.WriteString(" ")
-		b.WriteString(task.Version)
-		b.WriteString("\n")
-		// ports?
+		table.add(task.ID, task.Host, task.Version, joinInts(task.Ports), task.StartedAt)
 	}
-	title := "ID HOST VERSION\n"
-	text := title + b.String()
-	return Columnize(text)
+	return table
 }
 
 type TaskKill struct {
@@ -95,43 +102,70 @@ type TaskKill struct {
 	Formats Formatter
 }
 
-func (t TaskKill) Apply(args []string) {
+func (t TaskKill) flags(scale *bool) *flag.FlagSet {
+	fs := newFlagSet("task kill")
+	fs.BoolVar(scale, "scale", false, "scale the app down instead of replacing the tasks")
+	return fs
+}
+
+func (t TaskKill) Flags() *flag.FlagSet {
+	return t.flags(new(bool))
+}
+
+func (t TaskKill) Apply(ctx context.Context, args []string) {
+	var scale bool
+	args = flagsOf(t.flags(&scale), args)
 	switch len(args) {
 	case 1:
-		t.killAll(args[0])
+		t.killAll(ctx, args[0], scale)
 	case 2:
-		t.killOnly(args[0], args[1])
+		t.killOnly(ctx, args[0], args[1], scale)
 	default:
 		check.Check(false, "task kill takes 1 or 2 arguments")
 	}
 }
 
-func (t TaskKill) killAll(id string) {
-	esc := url.QueryEscape(id)
-	path := "/v2/apps/" + esc + "/tasks"
-	request := t.Clients.DELETE(path)
-	response, e := t.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
+func (t TaskKill) Usage() []Usage {
+	return []Usage{
+		{"[id]", "kill all tasks of app id"},
+		{"[id] [taskid]", "kill task taskid of app id"},
+	}
+}
 
-	sc := response.StatusCode
-	check.Check(sc != 404, "unknown id")
-	check.Check(sc == 200, "failed with status code", sc)
-	t.Formats.Format(response.Body, t.Humanize)
+func (t TaskKill) Complete(ctx context.Context, args []string) []string {
+	switch len(args) {
+	case 0:
+		return t.Clients.appIDs(ctx)
+	case 1:
+		return t.Clients.taskIDs(ctx, args[0])
+	}
+	return nil
+}
+
+func (t TaskKill) killAll(ctx context.Context, id string, scale bool) {
+	var raw []byte
+	var killed interface{}
+	var e error
+	if scale {
+		killed, e = t.Clients.KillTasksAndScale(withRaw(ctx, &raw), id)
+	} else {
+		killed, e = t.Clients.KillTasks(withRaw(ctx, &raw), id)
+	}
+	t.Formats.Check(e, "failed to kill tasks")
+	fmt.Println(t.Formats.For(killed).Format(bytes.NewReader(raw), t.Humanize))
 }
 
-func (t TaskKill) killOnly(id, taskid string) {
-	escID := url.QueryEscape(id)
-	escTaskID := url.QueryEscape(taskid)
-	path := "/v2/apps/" + escID + "/tasks/" + escTaskID
-	request := t.Clients.DELETE(path)
-	response, e := t.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-	sc := response.StatusCode
-	check.Check(sc != 404, "unknown appid or taskid")
-	check.Check(sc == 200, "failed with status code", sc)
-	t.Formats.Format(response.Body, t.Humanize)
+func (t TaskKill) killOnly(ctx context.Context, id, taskid string, scale bool) {
+	var raw []byte
+	var killed interface{}
+	var e error
+	if scale {
+		killed, e = t.Clients.KillTaskAndScale(withRaw(ctx, &raw), id, taskid)
+	} else {
+		killed, e = t.Clients.KillTask(withRaw(ctx, &raw), id, taskid)
+	}
+	t.Formats.Check(e, "failed to kill task")
+	fmt.Println(t.Formats.For(killed).Format(bytes.NewReader(raw), t.Humanize))
 }
 
 func (t TaskKill) Humanize(body io.Reader) string {
@@ -144,30 +178,38 @@ type TaskQueue struct {
 	Formats Formatter
 }
 
-func (t TaskQueue) Apply(args []string) {
+func (t TaskQueue) Apply(ctx context.Context, args []string) {
 	check.Check(len(args) == 0, "no arguments")
-	request := t.Clients.GET("/v2/queue")
-	response, e := t.Clients.Do(request)
-	check.Check(e == nil, "failed to get response", e)
-	defer response.Body.Close()
-	fmt.Println(t.Formats.Format(response.Body, t.Humanize))
+	var raw []byte
+	queue, e := t.Clients.ListQueue(withRaw(ctx, &raw))
+	t.Formats.Check(e, "failed to list queue")
+	fmt.Println(t.Formats.For(queue).FormatTable(bytes.NewReader(raw), t.Tabulate))
+}
+
+func (t TaskQueue) Usage() []Usage {
+	return []Usage{
+		{"", "list all queued tasks"},
+	}
 }
 
-func (t TaskQueue) Humanize(body io.Reader) string {
+func (t TaskQueue) Tabulate(body io.Reader) Table {
 	dec := json.NewDecoder(body)
 	var queue Queue
 	e := dec.Decode(&queue)
 	check.Check(e == nil, "failed to decode response", e)
-	title := "APP VERSION OVERDUE\n"
-	var b bytes.Buffer
+	table := newTable("APP", "VERSION", "OVERDUE")
+	table.wide("INSTANCES")
 	for _, queuedTask := range queue.Queue {
-		b.WriteString(queuedTask.App.ID)
-		b.WriteString(" ")
-		b.WriteString(queuedTask.App.Version)
-		b.WriteString(" ")
-		b.WriteString(strconv.FormatBool(queuedTask.Delay["overdue"]))
-		b.WriteString("\n")
+		table.add(queuedTask.App.ID, queuedTask.App.Version, strconv.FormatBool(queuedTask.Delay["overdue"]), strconv.Itoa(queuedTask.App.Instances))
+	}
+	return table
+}
+
+// joinInts joins ports and such with commas.
+func joinInts(list []int) string {
+	s := make([]string, len(list))
+	for i, n := range list {
+		s[i] = strconv.Itoa(n)
 	}
-	text := title + b.String()
-	return Columnize(text)
+	return strings.Join(s, ",")
 }
@@ -0,0 +1,100 @@
+package marathon
+
+// The template format, -f template=TEMPLATE or -f template-file=FILE,
+// which runs a text/template against the decoded response
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/layneYoo/mCtl/check"
+)
+
+// templateFuncs are the functions available to templates.
+var templateFuncs = template.FuncMap{
+	"join":     joinValues,
+	"json":     jsonValue,
+	"duration": since,
+	"pad":      padValue,
+}
+
+// parseTemplate parses the template given to -f, either text or the
+// contents of a file.
+func parseTemplate(text string, file bool) *template.Template {
+	if file {
+		b, e := ioutil.ReadFile(text)
+		check.Check(e == nil, "failed to read template file", e)
+		text = string(b)
+	}
+	t, e := template.New("format").Funcs(templateFuncs).Parse(text)
+	check.Check(e == nil, "failed to parse", e)
+	return t
+}
+
+// For returns a Formatter which runs templates against v, the
+// response decoded into the types of json.go. Without it templates
+// get the JSON of the response decoded into maps and lists.
+func (f Formatter) For(v interface{}) Formatter {
+	f.value = v
+	return f
+}
+
+// Templatize runs the template of f against the response.
+func (f Formatter) Templatize(input io.Reader) string {
+	b, e := ioutil.ReadAll(input)
+	check.Check(e == nil, "failed to read input", e)
+	data := f.value
+	if data == nil {
+		if e := json.Unmarshal(b, &data); e != nil {
+			data = string(b)
+		}
+	}
+	var s bytes.Buffer
+	e = f.template.Execute(&s, data)
+	check.Check(e == nil, "failed to execute", e)
+	return strings.TrimSuffix(s.String(), "\n")
+}
+
+// joinValues joins the items of list with sep, as in
+// {{join "," .Ports}}.
+func joinValues(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join needs a list, not %T", list)
+	}
+	items := make([]string, v.Len())
+	for i := range items {
+		items[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(items, sep), nil
+}
+
+// jsonValue returns v as JSON on one line, as in {{json .Labels}}.
+func jsonValue(v interface{}) (string, error) {
+	b, e := json.Marshal(v)
+	return string(b), e
+}
+
+// since returns the time passed since the timestamp, as in
+// {{duration .StartedAt}}, rounded to seconds. Timestamps which
+// do not parse give "".
+func since(timestamp string) string {
+	t, e := time.Parse(time.RFC3339, timestamp)
+	if e != nil {
+		return ""
+	}
+	return time.Since(t).Round(time.Second).String()
+}
+
+// padValue pads v with spaces to width characters, on the left if
+// width is negative, as in {{pad 30 .ID}}.
+func padValue(width int, v interface{}) string {
+	return fmt.Sprintf("%*v", -width, v)
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package marathon
+
+// stdoutWidth returns 0, tables are only truncated to $COLUMNS here.
+func stdoutWidth() int {
+	return 0
+}
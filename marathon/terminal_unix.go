@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package marathon
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// stdoutWidth returns the number of columns of the terminal on
+// stdout, or 0 if stdout is not a terminal.
+func stdoutWidth() int {
+	var size struct {
+		rows, cols, x, y uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0
+	}
+	return int(size.cols)
+}
@@ -0,0 +1,66 @@
+package marathon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLS configures how the Client talks to Marathon over https.
+type TLS struct {
+	CA       string `json:"ca,omitempty"`   // PEM file of CAs trusted in addition to the system pool
+	Cert     string `json:"cert,omitempty"` // PEM client certificate for mutual TLS
+	Key      string `json:"key,omitempty"`  // PEM private key of Cert
+	Insecure bool   `json:"insecure"`       // skip verification of the server certificate
+}
+
+func (t TLS) config() (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: t.Insecure}
+
+	if t.CA != "" {
+		pem, e := ioutil.ReadFile(t.CA)
+		if e != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", e)
+		}
+		pool, e := x509.SystemCertPool()
+		if e != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", t.CA)
+		}
+		c.RootCAs = pool
+	}
+
+	if t.Cert != "" || t.Key != "" {
+		if t.Cert == "" || t.Key == "" {
+			return nil, errors.New("client certificate and key must be given together")
+		}
+		cert, e := tls.LoadX509KeyPair(t.Cert, t.Key)
+		if e != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", e)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
+// describe explains certificate verification failures, which are
+// otherwise reported by net/http in a way that hides what to do.
+func describe(e error) string {
+	var unknown x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case errors.As(e, &unknown):
+		return "certificate signed by unknown authority, set marathon.tls.ca (-tls-ca) to the CA that signed it"
+	case errors.As(e, &hostname):
+		return fmt.Sprintf("certificate is not valid for %s: %v", hostname.Host, hostname)
+	case errors.As(e, &invalid):
+		return fmt.Sprintf("certificate is invalid: %v", invalid)
+	}
+	return e.Error()
+}
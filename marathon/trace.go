@@ -0,0 +1,103 @@
+package marathon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trace writes a description of a request sent to Marathon, and what
+// came back, on stderr. If o.TraceCurl is set an equivalent curl
+// command is written as well.
+func trace(r *http.Request, response *http.Response, e error, elapsed time.Duration, o Options) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "> %s %s (%d bytes)\n", r.Method, r.URL, r.ContentLength)
+	for _, name := range headerNames(r.Header) {
+		for _, value := range r.Header[name] {
+			fmt.Fprintf(&b, "> %s: %s\n", name, redact(name, value))
+		}
+	}
+	if e != nil {
+		fmt.Fprintf(&b, "< error after %s: %s\n", elapsed, describe(e))
+	} else {
+		fmt.Fprintf(&b, "< %s in %s\n", response.Status, elapsed)
+	}
+	if o.TraceCurl {
+		fmt.Fprintf(&b, "$ %s\n", curlCommand(r, o.TLS))
+	}
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+func headerNames(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// redact hides the value of the Authorization header, keeping the
+// scheme so that it is still clear what kind of auth was used.
+func redact(name, value string) string {
+	if http.CanonicalHeaderKey(name) != "Authorization" {
+		return value
+	}
+	if i := strings.IndexAny(value, " ="); i > 0 {
+		return value[:i+1] + "REDACTED"
+	}
+	return "REDACTED"
+}
+
+// curlCommand returns a curl command line sending the same request
+// as r over the same TLS settings, with the Authorization header
+// redacted.
+func curlCommand(r *http.Request, t TLS) string {
+	args := []string{"curl", "-sS", "-X", r.Method}
+	if t.Insecure {
+		args = append(args, "-k")
+	}
+	if t.CA != "" {
+		args = append(args, "--cacert", quote(t.CA))
+	}
+	if t.Cert != "" {
+		args = append(args, "--cert", quote(t.Cert), "--key", quote(t.Key))
+	}
+	for _, name := range headerNames(r.Header) {
+		for _, value := range r.Header[name] {
+			args = append(args, "-H", quote(name+": "+redact(name, value)))
+		}
+	}
+	if body := requestBody(r); body != "" {
+		args = append(args, "--data-binary", quote(body))
+	}
+	args = append(args, quote(r.URL.String()))
+	return strings.Join(args, " ")
+}
+
+// requestBody returns a copy of the body of r, if it can be read
+// again without disturbing the request.
+func requestBody(r *http.Request) string {
+	if r.Body == nil || r.GetBody == nil {
+		return ""
+	}
+	body, e := r.GetBody()
+	if e != nil {
+		return ""
+	}
+	defer body.Close()
+	b, e := ioutil.ReadAll(body)
+	if e != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// quote quotes s for a POSIX shell.
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,25 @@
+package marathon
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCurlCommand(t *testing.T) {
+	body := strings.Repeat("x", 100000) + "'"
+	r, e := http.NewRequest("PUT", "https://marathon1:8443/v2/apps/a", bytes.NewReader([]byte(body)))
+	if e != nil {
+		t.Fatal(e)
+	}
+	r.Header.Set("Authorization", "token=secret")
+	tls := TLS{CA: "/etc/ca.pem", Cert: "/etc/me.pem", Key: "/etc/me.key", Insecure: true}
+	got := curlCommand(r, tls)
+	want := "curl -sS -X PUT -k --cacert '/etc/ca.pem' --cert '/etc/me.pem' --key '/etc/me.key'" +
+		" -H 'Authorization: token=REDACTED'" +
+		" --data-binary '" + strings.Repeat("x", 100000) + `'\''' 'https://marathon1:8443/v2/apps/a'`
+	if got != want {
+		t.Errorf("got %.200s..., want %.200s...", got, want)
+	}
+}